@@ -0,0 +1,302 @@
+// Package audit provides the policy coverage and route audit for authj.
+package audit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/govaluate"
+	"github.com/emicklei/go-restful/v3"
+
+	"github.com/nova-clouds/restful-contrib/authj"
+)
+
+// SampleValue the value to substitute the path parameters of the route template,
+// so the route can be enforced as a request path.
+var SampleValue = "x"
+
+// Functions the custom matcher functions registered on the enforcer by AddFunction,
+// which can not be read back from the enforcer, so they must be registered here too
+// for the copy of the enforcer which analyzes the unused policies.
+var Functions = map[string]govaluate.ExpressionFunction{}
+
+var pathParameter = regexp.MustCompile(`\{[^}]*\}`)
+
+// Route a registered route to audit.
+type Route struct {
+	// Method the http method of the route.
+	Method string
+	// Path the path template of the route.
+	Path string
+	// Permission the permission annotated on the route, see authj.RequirePermission.
+	// Optional, if it is empty, the route is checked with (path, method).
+	Permission string
+}
+
+// String returns the route like "GET /orders/{id}".
+func (r Route) String() string {
+	return r.Method + " " + r.Path
+}
+
+// Request returns the (object, action) of the route to enforce,
+// it returns an error if the permission is invalid, see authj.ParsePermission.
+func (r Route) Request() (obj, act string, err error) {
+	if r.Permission != "" {
+		return authj.ParsePermission(r.Permission)
+	}
+	return pathParameter.ReplaceAllString(r.Path, SampleValue), r.Method, nil
+}
+
+// Report the policy coverage report.
+type Report struct {
+	// Subjects the subjects of the access matrix.
+	Subjects []string
+	// Routes the audited routes.
+	Routes []Route
+	// Matrix the access matrix, Matrix[i][j] report whether Subjects[i] can access Routes[j].
+	Matrix [][]bool
+	// UnreachableRoutes the routes no subject can reach.
+	UnreachableRoutes []Route
+	// UnusedPolicies the policies which change no decision of the subjects on the routes.
+	UnusedPolicies [][]string
+}
+
+// Allowed report whether the subject can access the route.
+func (r *Report) Allowed(subject string, route Route) bool {
+	i := slices.Index(r.Subjects, subject)
+	j := slices.Index(r.Routes, route)
+	return i >= 0 && j >= 0 && r.Matrix[i][j]
+}
+
+// RoutesFromContainer returns the registered routes of the container.
+func RoutesFromContainer(c *restful.Container) []Route {
+	var routes []Route
+	for _, ws := range c.RegisteredWebServices() {
+		for _, r := range ws.Routes() {
+			permission, _ := r.Metadata[authj.PermissionKey].(string)
+			routes = append(routes, Route{
+				Method:     r.Method,
+				Path:       r.Path,
+				Permission: permission,
+			})
+		}
+	}
+	return routes
+}
+
+// Analyze analyzes the policy coverage of the routes.
+// subjects are the subjects of the access matrix, if it is empty, use all the
+// subjects of the policies and the users of the grouping policies.
+func Analyze(e casbin.IEnforcer, routes []Route, subjects ...string) (*Report, error) {
+	var err error
+
+	if len(subjects) == 0 {
+		subjects, err = allSubjects(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+	report := &Report{
+		Subjects: subjects,
+		Routes:   routes,
+		Matrix:   make([][]bool, len(subjects)),
+	}
+	reachable := make([]bool, len(routes))
+	for i, sub := range subjects {
+		report.Matrix[i] = make([]bool, len(routes))
+		for j, route := range routes {
+			obj, act, err := route.Request()
+			if err != nil {
+				return nil, fmt.Errorf("audit: route %s, %w", route, err)
+			}
+			allowed, err := e.Enforce(sub, obj, act)
+			if err != nil {
+				return nil, fmt.Errorf("audit: enforce %s for %s, %w", route, sub, err)
+			}
+			report.Matrix[i][j] = allowed
+			reachable[j] = reachable[j] || allowed
+		}
+	}
+	for j, route := range routes {
+		if !reachable[j] {
+			report.UnreachableRoutes = append(report.UnreachableRoutes, route)
+		}
+	}
+	report.UnusedPolicies, err = unusedPolicies(e, routes, subjects)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// unusedPolicies removes each policy from a full copy of the enforcer,
+// it is unused if no decision of the subjects on the routes changes without it,
+// so the deny policies and the policies reached through the roles are checked too.
+func unusedPolicies(e casbin.IEnforcer, routes []Route, subjects []string) ([][]string, error) {
+	policies, err := e.GetPolicy()
+	if err != nil {
+		return nil, err
+	}
+	all, err := allSubjects(e)
+	if err != nil {
+		return nil, err
+	}
+	subjects = append(slices.Clone(subjects), all...)
+	slices.Sort(subjects)
+	subjects = slices.Compact(subjects)
+
+	full, err := copyEnforcer(e)
+	if err != nil {
+		return nil, err
+	}
+	base, err := decisions(full, routes, subjects)
+	if err != nil {
+		return nil, err
+	}
+	var unused [][]string
+	for _, policy := range policies {
+		c, err := copyEnforcer(e)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = c.RemovePolicy(policy); err != nil {
+			return nil, err
+		}
+		got, err := decisions(c, routes, subjects)
+		if err != nil {
+			return nil, fmt.Errorf("audit: without policy %v, %w", policy, err)
+		}
+		if slices.Equal(got, base) {
+			unused = append(unused, policy)
+		}
+	}
+	return unused, nil
+}
+
+// copyEnforcer returns a copy of the enforcer with the policies, the grouping policies,
+// the role managers of e and the custom matcher Functions,
+// the role managers are shared, as only the policies are changed on the copy.
+func copyEnforcer(e casbin.IEnforcer) (*casbin.Enforcer, error) {
+	src := e.GetModel()
+	c, err := casbin.NewEnforcer(src.Copy())
+	if err != nil {
+		return nil, err
+	}
+	for name, fn := range Functions {
+		c.AddFunction(name, fn)
+	}
+	for ptype, ast := range c.GetModel()["g"] {
+		if origin, ok := src["g"][ptype]; ok {
+			ast.RM, ast.CondRM = origin.RM, origin.CondRM
+		}
+	}
+	return c, nil
+}
+
+// decisions returns the decisions of the subjects on the routes.
+func decisions(e casbin.IEnforcer, routes []Route, subjects []string) ([]bool, error) {
+	result := make([]bool, 0, len(routes)*len(subjects))
+	for _, sub := range subjects {
+		for _, route := range routes {
+			obj, act, err := route.Request()
+			if err != nil {
+				return nil, fmt.Errorf("audit: route %s, %w", route, err)
+			}
+			allowed, err := e.Enforce(sub, obj, act)
+			if err != nil {
+				return nil, fmt.Errorf("audit: enforce %s for %s, %w", route, sub, err)
+			}
+			result = append(result, allowed)
+		}
+	}
+	return result, nil
+}
+
+func allSubjects(e casbin.IEnforcer) ([]string, error) {
+	subjects, err := e.GetAllSubjects()
+	if err != nil {
+		return nil, err
+	}
+	groupings, err := e.GetGroupingPolicy()
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groupings {
+		if len(g) > 0 {
+			subjects = append(subjects, g[0])
+		}
+	}
+	slices.Sort(subjects)
+	return slices.Compact(subjects), nil
+}
+
+// WriteRoutes writes the routes one per line in the form of "<method> <path> [permission]",
+// which can be read by ReadRoutes.
+func WriteRoutes(w io.Writer, routes []Route) error {
+	for _, r := range routes {
+		line := r.String()
+		if r.Permission != "" {
+			line += " " + r.Permission
+		}
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadRoutes reads the routes written by WriteRoutes,
+// the empty lines and the lines start with "#" are ignored.
+func ReadRoutes(r io.Reader) ([]Route, error) {
+	var routes []Route
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 && len(fields) != 3 {
+			return nil, fmt.Errorf("audit: invalid route at line %d: %q", n, line)
+		}
+		route := Route{Method: strings.ToUpper(fields[0]), Path: fields[1]}
+		if len(fields) == 3 {
+			route.Permission = fields[2]
+			if _, _, err := route.Request(); err != nil {
+				return nil, fmt.Errorf("audit: invalid route at line %d: %w", n, err)
+			}
+		}
+		routes = append(routes, route)
+	}
+	return routes, scanner.Err()
+}
+
+// WriteReport writes the human readable report.
+func WriteReport(w io.Writer, r *Report) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "unreachable routes (%d):\n", len(r.UnreachableRoutes))
+	for _, route := range r.UnreachableRoutes {
+		fmt.Fprintf(bw, "  %s\n", route)
+	}
+	fmt.Fprintf(bw, "unused policies (%d):\n", len(r.UnusedPolicies))
+	for _, policy := range r.UnusedPolicies {
+		fmt.Fprintf(bw, "  %s\n", strings.Join(policy, ", "))
+	}
+	fmt.Fprintln(bw, "access matrix:")
+	for j, route := range r.Routes {
+		var subjects []string
+		for i, sub := range r.Subjects {
+			if r.Matrix[i][j] {
+				subjects = append(subjects, sub)
+			}
+		}
+		fmt.Fprintf(bw, "  %s: %s\n", route, strings.Join(subjects, ", "))
+	}
+	return bw.Flush()
+}
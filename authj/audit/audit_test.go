@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/emicklei/go-restful/v3"
+
+	"github.com/nova-clouds/restful-contrib/authj"
+)
+
+func TestAnalyze(t *testing.T) {
+	e, _ := casbin.NewEnforcer("../authj_model.conf", "../authj_policy.csv")
+	_, _ = e.AddPolicy("alice", "orders", "read")
+
+	okfunc := func(req *restful.Request, resp *restful.Response) {}
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/dataset1/{id}").To(okfunc))
+	ws.Route(ws.POST("/dataset2/folder1/{id}").To(okfunc))
+	ws.Route(ws.GET("/dataset3/{id}").To(okfunc))
+	ws.Route(ws.GET("/orders").To(okfunc).Do(authj.RequirePermission("orders:read")))
+	router.Add(ws)
+
+	routes := RoutesFromContainer(router)
+	report, err := Analyze(e, routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.UnreachableRoutes, []Route{{Method: "GET", Path: "/dataset3/{id}"}}) {
+		t.Errorf("unexpected unreachable routes: %v", report.UnreachableRoutes)
+	}
+	wantUnused := [][]string{
+		{"alice", "/dataset1/resource1", "POST"},
+		{"bob", "/dataset2/resource1", "*"},
+		{"bob", "/dataset2/resource2", "GET"},
+	}
+	if !reflect.DeepEqual(report.UnusedPolicies, wantUnused) {
+		t.Errorf("unexpected unused policies: %v", report.UnusedPolicies)
+	}
+	if !report.Allowed("cathy", routes[0]) || report.Allowed("cathy", routes[1]) ||
+		!report.Allowed("bob", routes[1]) || !report.Allowed("alice", routes[3]) {
+		t.Errorf("unexpected access matrix: %v %v", report.Subjects, report.Matrix)
+	}
+
+	buf := &bytes.Buffer{}
+	if err = WriteRoutes(buf, routes); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadRoutes(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, routes) {
+		t.Errorf("routes round trip: %v, supposed to be %v", got, routes)
+	}
+}
+
+func TestAnalyzeRoleAndDeny(t *testing.T) {
+	m, err := model.NewModelFromString(`
+[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act, eft
+
+[role_definition]
+g = _, _
+
+[policy_effect]
+e = some(where (p.eft == allow)) && !some(where (p.eft == deny))
+
+[matchers]
+m = g(r.sub, p.sub) && r.obj == p.obj && sameAct(r.act, p.act)
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameAct := func(args ...any) (any, error) {
+		return args[0].(string) == args[1].(string), nil
+	}
+	Functions["sameAct"] = sameAct
+	defer delete(Functions, "sameAct")
+
+	e, _ := casbin.NewEnforcer(m)
+	e.AddFunction("sameAct", sameAct)
+	_, _ = e.AddPolicies([][]string{
+		{"admin", "/orders", "GET", "allow"},
+		{"bob", "/orders", "GET", "deny"},
+		{"admin", "/reports", "GET", "allow"},
+	})
+	_, _ = e.AddGroupingPolicies([][]string{{"alice", "admin"}, {"bob", "admin"}})
+
+	routes := []Route{{Method: "GET", Path: "/orders"}}
+	report, err := Analyze(e, routes, "alice", "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Allowed("alice", routes[0]) || report.Allowed("bob", routes[0]) {
+		t.Errorf("unexpected access matrix: %v %v", report.Subjects, report.Matrix)
+	}
+	want := [][]string{{"admin", "/reports", "GET", "allow"}}
+	if !reflect.DeepEqual(report.UnusedPolicies, want) {
+		t.Errorf("unexpected unused policies: %v", report.UnusedPolicies)
+	}
+	if ok, _ := e.Enforce("alice", "/orders", "GET"); !ok {
+		t.Errorf("the enforcer should not be changed by the analysis")
+	}
+}
@@ -6,11 +6,24 @@ import (
 
 	"github.com/casbin/casbin/v2"
 	"github.com/emicklei/go-restful/v3"
+	"go.uber.org/zap"
+
+	"github.com/nova-clouds/restful-contrib/problem"
+	"github.com/nova-clouds/restful-contrib/realip"
+	"github.com/nova-clouds/restful-contrib/subject"
 )
 
-// contextKey is a value for use with context.WithValue. It's used as
-// a pointer, so it fits in an interface{} without allocation.
-type ctxAuthKey struct{}
+// ctxDecisionKey is the key of the authorization decision.
+type ctxDecisionKey struct{}
+
+// Decision the authorization decision of a request.
+type Decision struct {
+	// Allowed the request is allowed or not.
+	Allowed bool
+	// Explain the matched rule when allowed, or the explaining rule when denied,
+	// it may be empty if no rule matched.
+	Explain []string
+}
 
 // Config for Authorizer
 type Config struct {
@@ -18,13 +31,17 @@ type Config struct {
 	forbiddenFallback  func(*restful.Request, *restful.Response)
 	skipAuthentication func(*restful.Request, *restful.Response) bool
 	subject            func(*restful.Request, *restful.Response) string
+	cache              *DecisionCache
+	logger             *zap.Logger
+	dryRun             bool
+	denyUnannotated    bool
 }
 
 // Option config option
 type Option func(*Config)
 
 // WithErrorFallback set the fallback handler when request are error happened.
-// default: the 500 server error to the client
+// default: the 500 server error problem details to the client
 func WithErrorFallback(fn func(*restful.Request, *restful.Response, error)) Option {
 	return func(cfg *Config) {
 		if fn != nil {
@@ -34,7 +51,7 @@ func WithErrorFallback(fn func(*restful.Request, *restful.Response, error)) Opti
 }
 
 // WithForbiddenFallback set the fallback handler when request are not allow.
-// default: the 403 Forbidden to the client
+// default: the 403 Forbidden problem details to the client
 func WithForbiddenFallback(fn func(*restful.Request, *restful.Response)) Option {
 	return func(cfg *Config) {
 		if fn != nil {
@@ -63,60 +80,152 @@ func WithSkipAuthentication(fn func(*restful.Request, *restful.Response) bool) O
 	}
 }
 
-// Authorizer returns the authorizer
-// uses a Casbin enforcer, and Subject as subject.
-func Authorizer(e casbin.IEnforcer, opts ...Option) restful.FilterFunction {
-	cfg := Config{
+// WithDecisionCache set the decision cache, hot paths will skip the evaluation.
+// NOTE: the policy changes made through the enforcer API, like AddPolicy or RemovePolicy,
+// are not observed by the cache, call DecisionCache.Invalidate after them,
+// or use DecisionCache.LoadPolicy/WatcherCallback to reload the policy.
+// default: nil, no cache
+func WithDecisionCache(c *DecisionCache) Option {
+	return func(cfg *Config) {
+		cfg.cache = c
+	}
+}
+
+// WithLogger set the logger, which logs the denied requests with the explaining rule.
+// default: nil, not log
+func WithLogger(l *zap.Logger) Option {
+	return func(cfg *Config) {
+		cfg.logger = l
+	}
+}
+
+// WithDryRun set the audit/dry-run mode, the would-be denied requests are logged,
+// but the requests continue.
+// default: false
+func WithDryRun(b bool) Option {
+	return func(cfg *Config) {
+		cfg.dryRun = b
+	}
+}
+
+// WithDenyUnannotated set PermissionAuthorizer denies the routes without the permission annotation,
+// so a route which forgets RequirePermission fails closed.
+// default: false, the unannotated routes are passed through.
+func WithDenyUnannotated(b bool) Option {
+	return func(cfg *Config) {
+		cfg.denyUnannotated = b
+	}
+}
+
+func newConfig() Config {
+	return Config{
 		func(req *restful.Request, resp *restful.Response, err error) {
-			resp.WriteHeaderAndJson( // nolint: errcheck
-				http.StatusInternalServerError, map[string]any{
-					"code": http.StatusInternalServerError,
-					"msg":  "Permission validation errors occur!",
-				},
-				restful.MIME_JSON,
-			)
+			problem.Write(req, resp, http.StatusInternalServerError, "Permission validation errors occur!") // nolint: errcheck
 		},
 		func(req *restful.Request, resp *restful.Response) {
-			resp.WriteHeaderAndJson( // nolint: errcheck
-				http.StatusForbidden, map[string]any{
-					"code": http.StatusForbidden,
-					"msg":  "Permission denied!",
-				},
-				restful.MIME_JSON,
-			)
+			problem.Write(req, resp, http.StatusForbidden, "Permission denied!") // nolint: errcheck
 		},
 		func(req *restful.Request, resp *restful.Response) bool { return false },
 		Subject,
+		nil,
+		nil,
+		false,
+		false,
 	}
+}
+
+// Authorizer returns the authorizer
+// uses a Casbin enforcer, and Subject as subject.
+func Authorizer(e casbin.IEnforcer, opts ...Option) restful.FilterFunction {
+	cfg := newConfig()
 	for _, opt := range opts {
 		opt(&cfg)
 	}
 	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
-		if !cfg.skipAuthentication(req, resp) {
+		if !cfg.skipAuthentication(req, resp) &&
 			// checks the subject,path,method permission combination from the request.
-			allowed, err := e.Enforce(cfg.subject(req, resp), req.Request.URL.Path, req.Request.Method)
-			if err != nil {
-				cfg.errFallback(req, resp, err)
-				return
-			}
-			if !allowed {
-				cfg.forbiddenFallback(req, resp)
-				return
-			}
+			!cfg.authorize(e, req, resp, req.Request.URL.Path, req.Request.Method) {
+			return
 		}
 		chain.ProcessFilter(req, resp)
 	}
 }
 
+// authorize checks the subject of the request has the (obj, act) permission,
+// it returns false if the fallback has been written to the response.
+func (cfg *Config) authorize(e casbin.IEnforcer, req *restful.Request, resp *restful.Response, obj, act string) bool {
+	sub := cfg.subject(req, resp)
+	d, err := cfg.enforce(e, sub, obj, act)
+	if err != nil {
+		cfg.errFallback(req, resp, err)
+		return false
+	}
+	ContextWithDecision(req, resp, d)
+	if !d.Allowed {
+		if cfg.logger != nil {
+			cfg.logger.Warn("permission denied",
+				zap.String("subject", sub),
+				zap.String("ip", realip.ClientIP(req.Request)),
+				zap.String("object", obj),
+				zap.String("action", act),
+				zap.Strings("explain", d.Explain),
+				zap.Bool("dryRun", cfg.dryRun),
+			)
+		}
+		if !cfg.dryRun {
+			cfg.forbiddenFallback(req, resp)
+			return false
+		}
+	}
+	return true
+}
+
+func (cfg *Config) enforce(e casbin.IEnforcer, sub, obj, act string) (Decision, error) {
+	var gen uint64
+
+	if cfg.cache != nil {
+		if d, ok := cfg.cache.Get(sub, obj, act); ok {
+			return d, nil
+		}
+		// read before the evaluation, the decision is dropped if invalidated meanwhile.
+		gen = cfg.cache.generation()
+	}
+	allowed, explain, err := e.EnforceEx(sub, obj, act)
+	if err != nil {
+		return Decision{}, err
+	}
+	d := Decision{allowed, explain}
+	if cfg.cache != nil {
+		cfg.cache.setIfGeneration(gen, sub, obj, act, d)
+	}
+	return d, nil
+}
+
 // Subject returns the value associated with this context for subjectCtxKey,
 func Subject(req *restful.Request, resp *restful.Response) string {
-	val, _ := req.Request.Context().Value(ctxAuthKey{}).(string)
-	return val
+	return SubjectFromContext(req.Request.Context())
+}
+
+// SubjectFromContext returns the subject from the context,
+// it returns the empty string if not present. see subject.FromSubject.
+func SubjectFromContext(ctx context.Context) string {
+	return subject.FromSubject(ctx)
 }
 
 // ContextWithSubject return a copy of parent in which the value associated with
 // subjectCtxKey is subject.
-func ContextWithSubject(req *restful.Request, resp *restful.Response, subject string) {
-	ctx := context.WithValue(req.Request.Context(), ctxAuthKey{}, subject)
+func ContextWithSubject(req *restful.Request, resp *restful.Response, sub string) {
+	req.Request = req.Request.WithContext(subject.WithSubject(req.Request.Context(), sub))
+}
+
+// DecisionFromContext returns the authorization decision of the request from the context.
+func DecisionFromContext(ctx context.Context) (d Decision, ok bool) {
+	d, ok = ctx.Value(ctxDecisionKey{}).(Decision)
+	return
+}
+
+// ContextWithDecision set the authorization decision to the request context.
+func ContextWithDecision(req *restful.Request, resp *restful.Response, d Decision) {
+	ctx := context.WithValue(req.Request.Context(), ctxDecisionKey{}, d)
 	req.Request = req.Request.WithContext(ctx)
 }
@@ -4,10 +4,14 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/casbin/casbin/v2"
 	"github.com/emicklei/go-restful/v3"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func testAuthjRequest(t *testing.T, router http.Handler, user, path, method string, code int) {
@@ -202,3 +206,197 @@ func TestSkipAuthentication(t *testing.T) {
 	testAuthjRequest(t, router, "cathy", "/dataset2/item", "POST", 403)
 	testAuthjRequest(t, router, "cathy", "/dataset2/item", "DELETE", 403)
 }
+
+func TestDecisionCache(t *testing.T) {
+	e, _ := casbin.NewEnforcer("authj_model.conf", "authj_policy.csv")
+	cache := NewDecisionCache(16, time.Minute)
+
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Filter(func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		ContextWithSubject(req, resp, "cathy")
+		chain.ProcessFilter(req, resp)
+	})
+	ws.Filter(Authorizer(e, WithDecisionCache(cache)))
+	okfunc := func(req *restful.Request, resp *restful.Response) {
+		resp.WriteHeader(200)
+	}
+	ws.Route(ws.GET("/{anypath:*}").To(okfunc))
+	router.Add(ws)
+
+	testAuthjRequest(t, router, "cathy", "/dataset1/item", "GET", 200)
+	testAuthjRequest(t, router, "cathy", "/dataset1/item", "GET", 200)
+	testAuthjRequest(t, router, "cathy", "/dataset2/item", "GET", 403)
+	if st := cache.Stats(); st.Hits != 1 || st.Misses != 2 || st.Size != 2 {
+		t.Errorf("unexpected cache stats: %+v", st)
+	}
+
+	// the cached decision is kept until the cache is invalidated.
+	_, _ = e.DeleteRolesForUser("cathy")
+	testAuthjRequest(t, router, "cathy", "/dataset1/item", "GET", 200)
+	cache.Invalidate()
+	testAuthjRequest(t, router, "cathy", "/dataset1/item", "GET", 403)
+
+	// reload the policy invalidates the cache too.
+	if err := cache.LoadPolicy(e); err != nil {
+		t.Fatal(err)
+	}
+	if st := cache.Stats(); st.Size != 0 {
+		t.Errorf("cache should be empty after reload policy: %+v", st)
+	}
+	testAuthjRequest(t, router, "cathy", "/dataset1/item", "GET", 200)
+}
+
+func TestDecisionCacheEviction(t *testing.T) {
+	cache := NewDecisionCache(2, 0)
+	cache.Set("alice", "/a", "GET", Decision{Allowed: true})
+	cache.Set("alice", "/b", "GET", Decision{Allowed: true})
+	_, _ = cache.Get("alice", "/a", "GET")
+	cache.Set("alice", "/c", "GET", Decision{})
+
+	if _, ok := cache.Get("alice", "/b", "GET"); ok {
+		t.Error("least recently used decision should be evicted")
+	}
+	if d, ok := cache.Get("alice", "/a", "GET"); !ok || !d.Allowed {
+		t.Error("recently used decision should be kept")
+	}
+
+	cache = NewDecisionCache(0, time.Millisecond)
+	cache.Set("alice", "/a", "GET", Decision{Allowed: true})
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cache.Get("alice", "/a", "GET"); ok {
+		t.Error("expired decision should be dropped")
+	}
+
+	// the decision evaluated before the invalidation is not cached.
+	cache = NewDecisionCache(0, 0)
+	gen := cache.generation()
+	cache.Invalidate()
+	cache.setIfGeneration(gen, "alice", "/a", "GET", Decision{Allowed: true})
+	if _, ok := cache.Get("alice", "/a", "GET"); ok {
+		t.Error("stale decision should not be cached")
+	}
+	cache.setIfGeneration(cache.generation(), "alice", "/a", "GET", Decision{Allowed: true})
+	if _, ok := cache.Get("alice", "/a", "GET"); !ok {
+		t.Error("decision should be cached")
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	e, _ := casbin.NewEnforcer("authj_model.conf", "authj_policy.csv")
+	core, logs := observer.New(zap.InfoLevel)
+
+	var decision Decision
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Filter(func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		ContextWithSubject(req, resp, "alice")
+		chain.ProcessFilter(req, resp)
+	})
+	ws.Filter(Authorizer(e, WithLogger(zap.New(core)), WithDryRun(true)))
+	ws.Route(ws.GET("/{anypath:*}").To(func(req *restful.Request, resp *restful.Response) {
+		decision, _ = DecisionFromContext(req.Request.Context())
+		resp.WriteHeader(200)
+	}))
+	router.Add(ws)
+
+	testAuthjRequest(t, router, "alice", "/dataset1/resource1", "GET", 200)
+	if !decision.Allowed || !reflect.DeepEqual(decision.Explain, []string{"alice", "/dataset1/*", "GET"}) {
+		t.Errorf("unexpected decision: %+v", decision)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("allowed request should not be logged")
+	}
+
+	testAuthjRequest(t, router, "alice", "/dataset2/resource1", "GET", 200)
+	if decision.Allowed {
+		t.Errorf("unexpected decision: %+v", decision)
+	}
+	entries := logs.FilterMessage("permission denied").All()
+	if len(entries) != 1 || !entries[0].ContextMap()["dryRun"].(bool) {
+		t.Errorf("would-be denied request should be logged: %+v", entries)
+	}
+}
+
+func TestPermissionAuthorizer(t *testing.T) {
+	e, _ := casbin.NewEnforcer("authj_model.conf", "authj_policy.csv")
+	_, _ = e.AddPolicy("alice", "orders", "read")
+
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Filter(func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		ContextWithSubject(req, resp, "alice")
+		chain.ProcessFilter(req, resp)
+	})
+	ws.Filter(PermissionAuthorizer(e))
+	okfunc := func(req *restful.Request, resp *restful.Response) {
+		resp.WriteHeader(200)
+	}
+	ws.Route(ws.GET("/orders").To(okfunc).Do(RequirePermission("orders:read")))
+	ws.Route(ws.POST("/orders").To(okfunc).Do(RequirePermission("orders:write")))
+	ws.Route(ws.GET("/public").To(okfunc))
+	router.Add(ws)
+
+	testAuthjRequest(t, router, "alice", "/orders", "GET", 200)
+	testAuthjRequest(t, router, "alice", "/orders", "POST", 403)
+	testAuthjRequest(t, router, "alice", "/public", "GET", 200)
+
+	routes := UnannotatedRoutes(router)
+	if len(routes) != 1 || routes[0].Path != "/public" {
+		t.Errorf("unexpected unannotated routes: %+v", routes)
+	}
+
+	// the invalid permission set by hand is an error.
+	router = restful.NewContainer()
+	ws = new(restful.WebService)
+	ws.Filter(PermissionAuthorizer(e))
+	ws.Route(ws.GET("/orders").To(okfunc).Metadata(PermissionKey, "orders"))
+	router.Add(ws)
+	testAuthjRequest(t, router, "alice", "/orders", "GET", 500)
+
+	// deny the unannotated routes.
+	core, logs := observer.New(zap.InfoLevel)
+	router = restful.NewContainer()
+	ws = new(restful.WebService)
+	ws.Filter(func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		ContextWithSubject(req, resp, "alice")
+		chain.ProcessFilter(req, resp)
+	})
+	ws.Filter(PermissionAuthorizer(e, WithDenyUnannotated(true), WithLogger(zap.New(core))))
+	ws.Route(ws.GET("/orders").To(okfunc).Do(RequirePermission("orders:read")))
+	ws.Route(ws.GET("/public").To(okfunc))
+	router.Add(ws)
+
+	testAuthjRequest(t, router, "alice", "/orders", "GET", 200)
+	testAuthjRequest(t, router, "alice", "/public", "GET", 403)
+	if n := logs.FilterField(zap.String("route", "/public")).Len(); n != 1 {
+		t.Errorf("denied unannotated route should be logged, got %d", n)
+	}
+}
+
+func TestParsePermission(t *testing.T) {
+	var tests = []struct {
+		permission string
+		obj, act   string
+		wantErr    bool
+	}{
+		{"orders:read", "orders", "read", false},
+		{"api:orders:*", "api:orders", "*", false},
+		{"orders", "", "", true},
+		{":read", "", "", true},
+		{"orders:", "", "", true},
+	}
+	for _, tt := range tests {
+		obj, act, err := ParsePermission(tt.permission)
+		if (err != nil) != tt.wantErr || obj != tt.obj || act != tt.act {
+			t.Errorf("ParsePermission(%q) = %q, %q, %v", tt.permission, obj, act, err)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("RequirePermission should panic with the permission without the action")
+		}
+	}()
+	RequirePermission("orders")
+}
@@ -0,0 +1,161 @@
+package authj
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+)
+
+// cacheKey is the (subject, object, action) tuple of a decision.
+type cacheKey struct {
+	sub, obj, act string
+}
+
+type cacheEntry struct {
+	key      cacheKey
+	decision Decision
+	expireAt time.Time
+}
+
+// CacheStats is a snapshot of the decision cache counters.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+	Size   int
+}
+
+// DecisionCache is a LRU decision cache with an optional TTL, keyed by
+// (subject, object, action).
+// It is safe for concurrent use.
+//
+// NOTE: The cache can not observe policy changes made on the enforcer,
+// call Invalidate (or use LoadPolicy/WatcherCallback) after the policy changed.
+type DecisionCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	ll       *list.List
+	items    map[cacheKey]*list.Element
+	gen      uint64 // bumped by Invalidate, guarded by mu
+	hits     atomic.Uint64
+	misses   atomic.Uint64
+}
+
+// NewDecisionCache new a decision cache.
+// capacity is the max number of decisions, <=0 means no limit.
+// ttl is the time to live of a decision, <=0 means never expire.
+func NewDecisionCache(capacity int, ttl time.Duration) *DecisionCache {
+	return &DecisionCache{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[cacheKey]*list.Element),
+	}
+}
+
+// Get returns the cached decision of (sub, obj, act).
+func (c *DecisionCache) Get(sub, obj, act string) (Decision, bool) {
+	key := cacheKey{sub, obj, act}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, hit := c.items[key]; hit {
+		entry := e.Value.(*cacheEntry)
+		if entry.expireAt.IsZero() || time.Now().Before(entry.expireAt) {
+			c.ll.MoveToFront(e)
+			c.hits.Add(1)
+			return entry.decision, true
+		}
+		c.removeElement(e)
+	}
+	c.misses.Add(1)
+	return Decision{}, false
+}
+
+// Set caches the decision of (sub, obj, act).
+func (c *DecisionCache) Set(sub, obj, act string, d Decision) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(sub, obj, act, d)
+}
+
+// generation returns the generation of the cache, which changes on Invalidate.
+func (c *DecisionCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// setIfGeneration caches the decision only if the cache is not invalidated since the generation,
+// so the decision evaluated with the stale policy is dropped.
+func (c *DecisionCache) setIfGeneration(gen uint64, sub, obj, act string, d Decision) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen == gen {
+		c.set(sub, obj, act, d)
+	}
+}
+
+// set caches the decision, the caller must hold mu.
+func (c *DecisionCache) set(sub, obj, act string, d Decision) {
+	key := cacheKey{sub, obj, act}
+	var expireAt time.Time
+	if c.ttl > 0 {
+		expireAt = time.Now().Add(c.ttl)
+	}
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		entry := e.Value.(*cacheEntry)
+		entry.decision = d
+		entry.expireAt = expireAt
+		return
+	}
+	c.items[key] = c.ll.PushFront(&cacheEntry{key, d, expireAt})
+	if c.capacity > 0 && c.ll.Len() > c.capacity {
+		if e := c.ll.Back(); e != nil {
+			c.removeElement(e)
+		}
+	}
+}
+
+// Invalidate drops all the cached decisions.
+func (c *DecisionCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.ll.Init()
+	clear(c.items)
+}
+
+// Stats returns the hit/miss counters and the current size of the cache.
+func (c *DecisionCache) Stats() CacheStats {
+	c.mu.Lock()
+	size := c.ll.Len()
+	c.mu.Unlock()
+	return CacheStats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+		Size:   size,
+	}
+}
+
+// LoadPolicy reloads the policy of the enforcer and invalidates the cache.
+func (c *DecisionCache) LoadPolicy(e casbin.IEnforcer) error {
+	defer c.Invalidate()
+	return e.LoadPolicy()
+}
+
+// WatcherCallback returns a callback for persist.Watcher.SetUpdateCallback,
+// which reloads the policy of the enforcer and invalidates the cache.
+func (c *DecisionCache) WatcherCallback(e casbin.IEnforcer) func(string) {
+	return func(string) {
+		_ = c.LoadPolicy(e)
+	}
+}
+
+func (c *DecisionCache) removeElement(e *list.Element) {
+	c.ll.Remove(e)
+	delete(c.items, e.Value.(*cacheEntry).key)
+}
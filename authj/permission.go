@@ -0,0 +1,114 @@
+package authj
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/emicklei/go-restful/v3"
+	"go.uber.org/zap"
+
+	"github.com/nova-clouds/restful-contrib/realip"
+)
+
+// PermissionKey is the route metadata key of the required permission.
+const PermissionKey = "authj.permission"
+
+// RequirePermission returns a route builder function which annotates the route
+// with the required permission, it is a string in the form of "<object>:<action>",
+// it panics if the permission is invalid, see ParsePermission.
+// use like:
+//
+//	ws.Route(ws.GET("/orders").To(listOrders).Do(authj.RequirePermission("orders:read")))
+func RequirePermission(permission string) func(*restful.RouteBuilder) {
+	if _, _, err := ParsePermission(permission); err != nil {
+		panic(err)
+	}
+	return func(b *restful.RouteBuilder) {
+		b.Metadata(PermissionKey, permission)
+	}
+}
+
+// Permission returns the permission annotated on the route, and report whether it is present.
+func Permission(route restful.RouteReader) (string, bool) {
+	if route == nil {
+		return "", false
+	}
+	permission, ok := route.Metadata()[PermissionKey].(string)
+	return permission, ok && permission != ""
+}
+
+// ParsePermission parses the permission "<object>:<action>" into object and action,
+// both the object and the action are required, as the action is matched with the
+// policy action, a permission without the action can never be granted by a concrete action.
+func ParsePermission(permission string) (obj, act string, err error) {
+	i := strings.LastIndexByte(permission, ':')
+	if i <= 0 || i == len(permission)-1 {
+		return "", "", fmt.Errorf("authj: invalid permission %q, supposed to be <object>:<action>", permission)
+	}
+	return permission[:i], permission[i+1:], nil
+}
+
+// PermissionAuthorizer returns the authorizer which checks the subject has the
+// permission annotated on the route by RequirePermission through the Casbin enforcer.
+// The routes without the permission annotation are passed through unless WithDenyUnannotated,
+// use UnannotatedRoutes to report them.
+func PermissionAuthorizer(e casbin.IEnforcer, opts ...Option) restful.FilterFunction {
+	cfg := newConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		if !cfg.skipAuthentication(req, resp) {
+			if permission, ok := Permission(req.SelectedRoute()); ok {
+				obj, act, err := ParsePermission(permission)
+				if err != nil {
+					cfg.errFallback(req, resp, err)
+					return
+				}
+				if !cfg.authorize(e, req, resp, obj, act) {
+					return
+				}
+			} else if cfg.denyUnannotated && !cfg.denyUnannotatedRoute(req, resp) {
+				return
+			}
+		}
+		chain.ProcessFilter(req, resp)
+	}
+}
+
+// denyUnannotatedRoute denies the request of the route without the permission annotation,
+// it returns false if the fallback has been written to the response.
+func (cfg *Config) denyUnannotatedRoute(req *restful.Request, resp *restful.Response) bool {
+	if cfg.logger != nil {
+		route := ""
+		if r := req.SelectedRoute(); r != nil {
+			route = r.Path()
+		}
+		cfg.logger.Warn("permission denied, route is not annotated",
+			zap.String("subject", cfg.subject(req, resp)),
+			zap.String("ip", realip.ClientIP(req.Request)),
+			zap.String("method", req.Request.Method),
+			zap.String("route", route),
+			zap.Bool("dryRun", cfg.dryRun),
+		)
+	}
+	if cfg.dryRun {
+		return true
+	}
+	cfg.forbiddenFallback(req, resp)
+	return false
+}
+
+// UnannotatedRoutes returns the routes of the container which have no permission annotation.
+func UnannotatedRoutes(c *restful.Container) []restful.Route {
+	var routes []restful.Route
+	for _, ws := range c.RegisteredWebServices() {
+		for _, r := range ws.Routes() {
+			if permission, ok := r.Metadata[PermissionKey].(string); !ok || permission == "" {
+				routes = append(routes, r)
+			}
+		}
+	}
+	return routes
+}
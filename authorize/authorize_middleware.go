@@ -1,10 +1,11 @@
 package authorize
 
 import (
-	"io"
 	"net/http"
 
 	"github.com/emicklei/go-restful/v3"
+
+	"github.com/nova-clouds/restful-contrib/problem"
 )
 
 // Option is Middleware option.
@@ -26,6 +27,7 @@ func WithSkip(f func(req *restful.Request, resp *restful.Response) bool) Option
 }
 
 // WithUnauthorizedFallback sets the fallback handler when requests are unauthorized.
+// default: the 401 Unauthorized problem details to the client
 func WithUnauthorizedFallback(f func(req *restful.Request, resp *restful.Response, err error)) Option {
 	return func(o *options) {
 		if f != nil {
@@ -37,8 +39,7 @@ func WithUnauthorizedFallback(f func(req *restful.Request, resp *restful.Respons
 func (a *Auth[T]) Middleware(opts ...Option) restful.FilterFunction {
 	o := &options{
 		unauthorizedFallback: func(req *restful.Request, resp *restful.Response, err error) {
-			resp.WriteHeader(http.StatusUnauthorized)
-			_, _ = io.WriteString(resp, err.Error())
+			_ = problem.Write(req, resp, http.StatusUnauthorized, err.Error())
 		},
 		skip: func(req *restful.Request, resp *restful.Response) bool { return false },
 	}
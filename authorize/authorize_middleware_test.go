@@ -0,0 +1,60 @@
+package authorize
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nova-clouds/restful-contrib/problem"
+)
+
+func TestMiddleware(t *testing.T) {
+	auth, err := New[string](Config{
+		Timeout:   time.Hour,
+		Algorithm: "HS256",
+		Key:       "secret",
+	})
+	require.NoError(t, err)
+
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Filter(auth.Middleware())
+	ws.Route(ws.GET("/orders").To(func(req *restful.Request, resp *restful.Response) {
+		_, ok := FromContext[string](req.Request.Context())
+		require.True(t, ok)
+	}))
+	router.Add(ws)
+
+	t.Run("unauthorized problem", func(t *testing.T) {
+		r, _ := http.NewRequestWithContext(context.TODO(), http.MethodGet, "/orders", http.NoBody)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+		require.Equal(t, problem.MIME_PROBLEM_JSON, w.Header().Get("Content-Type"))
+		var d problem.Details
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &d))
+		require.Equal(t, http.StatusUnauthorized, d.Status)
+		require.Equal(t, "/orders", d.Instance)
+		require.NotEmpty(t, d.Detail)
+	})
+	t.Run("authorized", func(t *testing.T) {
+		token, _, err := auth.GenerateToken(&Claims[string]{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "alice", ID: "1"},
+		})
+		require.NoError(t, err)
+
+		r, _ := http.NewRequestWithContext(context.TODO(), http.MethodGet, "/orders", http.NoBody)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+	})
+}
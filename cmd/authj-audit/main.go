@@ -0,0 +1,70 @@
+// Command authj-audit reports the policy coverage of the routes.
+//
+// usage:
+//
+//	authj-audit -model authj_model.conf -policy authj_policy.csv -routes routes.txt
+//
+// the routes file contains one route per line in the form of "<method> <path> [permission]",
+// which can be generated by audit.WriteRoutes with audit.RoutesFromContainer.
+// It exits with code 1 if there are unreachable routes or unused policies when -strict is set.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/casbin/casbin/v2"
+
+	"github.com/nova-clouds/restful-contrib/authj/audit"
+)
+
+func main() {
+	modelFile := flag.String("model", "", "casbin model file")
+	policyFile := flag.String("policy", "", "casbin policy file")
+	routesFile := flag.String("routes", "", "routes file, one route per line: <method> <path> [permission]")
+	subjects := flag.String("subjects", "", "comma separated subjects of the access matrix, default all subjects")
+	strict := flag.Bool("strict", false, "exit with code 1 if there are unreachable routes or unused policies")
+	flag.Parse()
+
+	if *modelFile == "" || *policyFile == "" || *routesFile == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	report, err := run(*modelFile, *policyFile, *routesFile, *subjects)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err = audit.WriteReport(os.Stdout, report); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *strict && (len(report.UnreachableRoutes) > 0 || len(report.UnusedPolicies) > 0) {
+		os.Exit(1)
+	}
+}
+
+func run(modelFile, policyFile, routesFile, subjects string) (*audit.Report, error) {
+	e, err := casbin.NewEnforcer(modelFile, policyFile)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(routesFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint: errcheck
+	routes, err := audit.ReadRoutes(f)
+	if err != nil {
+		return nil, err
+	}
+	var subs []string
+	if subjects != "" {
+		for _, s := range strings.Split(subjects, ",") {
+			subs = append(subs, strings.TrimSpace(s))
+		}
+	}
+	return audit.Analyze(e, routes, subs...)
+}
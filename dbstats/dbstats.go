@@ -0,0 +1,102 @@
+// Package dbstats provides the per-request SQL statistics carried in the context,
+// the SQL logger records the queries, and the access logger reports them.
+package dbstats
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ctxStatsKey struct{}
+
+// Stats the SQL statistics of a request. It is safe for concurrent use.
+type Stats struct {
+	mu           sync.Mutex
+	queries      int
+	total        time.Duration
+	slowest      time.Duration
+	slowestQuery string
+	fingerprints map[string]int
+}
+
+// Repeat a fingerprint which repeats in a request.
+type Repeat struct {
+	Fingerprint string
+	Count       int
+}
+
+// New new a stats.
+func New() *Stats {
+	return &Stats{fingerprints: make(map[string]int)}
+}
+
+// NewContext returns a copy of the parent context carrying the stats.
+func NewContext(ctx context.Context, s *Stats) context.Context {
+	return context.WithValue(ctx, ctxStatsKey{}, s)
+}
+
+// FromContext returns the stats carried in the context, nil if not present.
+func FromContext(ctx context.Context) *Stats {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(ctxStatsKey{}).(*Stats)
+	return s
+}
+
+// Record records a query with the fingerprint and the duration.
+func (s *Stats) Record(fingerprint string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries++
+	s.total += d
+	if d > s.slowest || s.queries == 1 {
+		s.slowest = d
+		s.slowestQuery = fingerprint
+	}
+	s.fingerprints[fingerprint]++
+}
+
+// Queries returns the number of the queries.
+func (s *Stats) Queries() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queries
+}
+
+// Total returns the total duration of the queries.
+func (s *Stats) Total() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.total
+}
+
+// Slowest returns the duration and the fingerprint of the slowest query.
+func (s *Stats) Slowest() (time.Duration, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.slowest, s.slowestQuery
+}
+
+// Repeated returns the fingerprints which repeat more than threshold times,
+// the most repeated first, it is the sign of the N+1 queries.
+func (s *Stats) Repeated(threshold int) []Repeat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var repeats []Repeat
+	for fingerprint, count := range s.fingerprints {
+		if count > threshold {
+			repeats = append(repeats, Repeat{fingerprint, count})
+		}
+	}
+	slices.SortFunc(repeats, func(a, b Repeat) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.Fingerprint, b.Fingerprint)
+	})
+	return repeats
+}
@@ -0,0 +1,41 @@
+package dbstats
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	if FromContext(context.Background()) != nil {
+		t.Fatal("stats should not be present")
+	}
+	s := New()
+	ctx := NewContext(context.Background(), s)
+	if FromContext(ctx) != s {
+		t.Fatal("stats should be carried in the context")
+	}
+
+	s.Record("SELECT * FROM users WHERE id = ?", 2*time.Millisecond)
+	s.Record("SELECT * FROM users WHERE id = ?", 3*time.Millisecond)
+	s.Record("SELECT * FROM orders", 10*time.Millisecond)
+	s.Record("SELECT * FROM users WHERE id = ?", time.Millisecond)
+
+	if got := s.Queries(); got != 4 {
+		t.Errorf("Queries() = %d, want 4", got)
+	}
+	if got := s.Total(); got != 16*time.Millisecond {
+		t.Errorf("Total() = %v, want 16ms", got)
+	}
+	if d, fingerprint := s.Slowest(); d != 10*time.Millisecond || fingerprint != "SELECT * FROM orders" {
+		t.Errorf("Slowest() = %v, %q", d, fingerprint)
+	}
+	want := []Repeat{{"SELECT * FROM users WHERE id = ?", 3}}
+	if got := s.Repeated(2); !reflect.DeepEqual(got, want) {
+		t.Errorf("Repeated() = %v, want %v", got, want)
+	}
+	if got := s.Repeated(3); len(got) != 0 {
+		t.Errorf("Repeated() = %v, want empty", got)
+	}
+}
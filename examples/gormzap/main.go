@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"time"
 
 	"github.com/nova-clouds/restful-contrib/gormzap"
@@ -18,17 +17,9 @@ func main() {
 	log := gormzap.New(zapL,
 		gormzap.WithCustomFields(
 			gormzap.String("service", "test"),
-			func(ctx context.Context) zap.Field {
-				v := ctx.Value("requestId")
-				if v == nil {
-					return zap.Skip()
-				}
-				if vv, ok := v.(string); ok {
-					return zap.String("requestId", vv)
-				}
-				return zap.Skip()
-			},
 		),
+		// trace id, subject and route from the request context, use db.WithContext(req.Request.Context())
+		gormzap.WithCorrelation(true),
 		gormzap.WithConfig(logger.Config{
 			SlowThreshold:             200 * time.Millisecond,
 			Colorful:                  false,
@@ -10,6 +10,7 @@ import (
 
 	"github.com/emicklei/go-restful/v3"
 	"github.com/nova-clouds/restful-contrib/gzap"
+	"github.com/nova-clouds/restful-contrib/realip"
 	"go.uber.org/zap"
 )
 
@@ -18,6 +19,9 @@ func main() {
 
 	logger, _ := zap.NewProduction()
 
+	// resolve the real client ip behind the trusted proxies.
+	ws.Filter(realip.RealIP(realip.WithTrustedPrivateProxies()))
+
 	// Add a ginzap middleware, which:
 	//   - Logs all requests, like a combined access and error log.
 	//   - Logs to stdout.
@@ -26,10 +30,10 @@ func main() {
 		gzap.WithCustomFields(
 			gzap.String("app", "example"),
 			func(req *restful.Request, resp *restful.Response) zap.Field {
-				return zap.String("custom field1", req.Request.URL.RawPath /*c.ClientIP()*/)
+				return zap.String("custom field1", realip.ClientIP(req.Request))
 			},
 			func(req *restful.Request, resp *restful.Response) zap.Field {
-				return zap.String("custom field2", req.Request.URL.RawPath /*c.ClientIP()*/)
+				return zap.String("custom field2", realip.ClientIP(req.Request))
 			},
 		),
 		gzap.WithSkipLogging(func(req *restful.Request, resp *restful.Response) bool {
@@ -37,6 +41,7 @@ func main() {
 		}),
 		gzap.WithEnableBody(true),
 		gzap.WithEnableDebugCurl(true),
+		gzap.WithRedactor(gzap.DefaultRedactor()),
 	))
 
 	// Logs all panic to error log
@@ -45,10 +50,10 @@ func main() {
 		gzap.WithCustomFields(
 			gzap.Any("app", "example"),
 			func(req *restful.Request, resp *restful.Response) zap.Field {
-				return zap.String("custom field1", req.Request.URL.RawPath /*c.ClientIP()*/)
+				return zap.String("custom field1", realip.ClientIP(req.Request))
 			},
 			func(req *restful.Request, resp *restful.Response) zap.Field {
-				return zap.String("custom field2", req.Request.URL.RawPath /*c.ClientIP()*/)
+				return zap.String("custom field2", realip.ClientIP(req.Request))
 			},
 		),
 	))
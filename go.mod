@@ -4,6 +4,7 @@ go 1.24
 
 require (
 	github.com/casbin/casbin/v2 v2.105.0
+	github.com/casbin/govaluate v1.3.0
 	github.com/emicklei/go-restful/v3 v3.12.2
 	github.com/golang-jwt/jwt/v5 v5.2.2
 	github.com/oklog/ulid/v2 v2.1.0
@@ -15,7 +16,6 @@ require (
 
 require (
 	github.com/bmatcuk/doublestar/v4 v4.8.1 // indirect
-	github.com/casbin/govaluate v1.3.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
@@ -0,0 +1,200 @@
+package gormzap
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// `IN (?, ?, ?)` -> `IN (?)`
+	repeatedPlaceholders = regexp.MustCompile(`\(\?(?:\s*,\s*\?)+\)`)
+	// `VALUES (?), (?)` -> `VALUES (?)`
+	repeatedRows = regexp.MustCompile(`(\([?, ]+\))(?:\s*,\s*\([?, ]+\))+`)
+)
+
+// Fingerprint returns the normalized SQL, the literal values and the placeholders are replaced by "?",
+// the comments are removed, the whitespaces are collapsed, the placeholder lists and the rows are folded,
+// so the same statement with the different values has the same fingerprint.
+// The double-quoted strings are identifiers as the ANSI SQL, see FingerprintQuote for the dialect
+// which explains the string values with the double quote, like sqlite.
+// like: SELECT * FROM `users` WHERE id = 1 AND name IN ('a','b') -> SELECT * FROM `users` WHERE id = ? AND name IN (?)
+func Fingerprint(sql string) string {
+	return FingerprintQuote(sql, '\'')
+}
+
+// FingerprintQuote is like Fingerprint, but the strings quoted by quote are the literal values too,
+// quote is the escaper which the dialect explains the SQL with, like '"' of sqlite.
+// quote 0 means the dialect is unknown, it fails closed: both the single and the double-quoted
+// strings are the literal values, so the double-quoted identifiers are dropped too.
+func FingerprintQuote(sql string, quote byte) string {
+	b := strings.Builder{}
+	b.Grow(len(sql))
+	space := false
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			i++
+			continue
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			// line comment
+			if j := strings.IndexByte(sql[i:], '\n'); j >= 0 {
+				i += j
+			} else {
+				i = len(sql)
+			}
+			space = true
+			continue
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			// block comment
+			if j := strings.Index(sql[i+2:], "*/"); j >= 0 {
+				i += j + 4
+			} else {
+				i = len(sql)
+			}
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+
+		switch {
+		case c == '\'' || c == quote || (quote == 0 && c == '"'):
+			// string literal
+			i = skipQuoted(sql, i, c)
+			b.WriteByte('?')
+		case c == '"' || c == '`':
+			// quoted identifier
+			j := skipQuoted(sql, i, c)
+			b.WriteString(sql[i:j])
+			i = j
+		case c == '$' && i+1 < len(sql) && isDigit(sql[i+1]):
+			// positional placeholder, like $1
+			for i++; i < len(sql) && isDigit(sql[i]); i++ {
+			}
+			b.WriteByte('?')
+		case isDigit(c) && (i == 0 || !isWord(sql[i-1])):
+			// number literal, like 1, 1.5, 1e10, 0x1F
+			for i++; i < len(sql) && (isWord(sql[i]) || sql[i] == '.'); i++ {
+			}
+			b.WriteByte('?')
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	s := repeatedPlaceholders.ReplaceAllString(b.String(), "(?)")
+	return repeatedRows.ReplaceAllString(s, "$1")
+}
+
+// ParseStatement returns the operation and the table of the SQL statement,
+// the operation is the lower case first keyword, like "select", "insert", "update", "delete",
+// the table is the first table after "FROM", "INTO", "UPDATE" or "JOIN" outside the parentheses,
+// it is empty if not found.
+func ParseStatement(sql string) (operation, table string) {
+	tokens := tokenize(sql)
+	if len(tokens) == 0 {
+		return "", ""
+	}
+	operation = strings.ToLower(tokens[0].text)
+	for i, t := range tokens {
+		if t.depth != 0 || i+1 >= len(tokens) {
+			continue
+		}
+		switch strings.ToUpper(t.text) {
+		case "FROM", "INTO", "JOIN":
+		case "UPDATE":
+			if i != 0 {
+				continue
+			}
+		default:
+			continue
+		}
+		if next := tokens[i+1]; next.depth == 0 && next.text != "(" {
+			return operation, unquoteIdentifier(next.text)
+		}
+	}
+	return operation, ""
+}
+
+type token struct {
+	text  string
+	depth int
+}
+
+// tokenize splits the SQL into the words, the quoted identifiers and the parentheses,
+// the literals and the punctuations are dropped.
+func tokenize(sql string) []token {
+	var tokens []token
+
+	depth := 0
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '(':
+			tokens = append(tokens, token{"(", depth})
+			depth++
+			i++
+		case c == ')':
+			depth = max(depth-1, 0)
+			i++
+		case c == '\'':
+			i = skipQuoted(sql, i, '\'')
+		case c == '"' || c == '`' || c == '[' || isWord(c):
+			// identifier, maybe qualified like `db`.`users`
+			j := i
+			for j < len(sql) {
+				switch sql[j] {
+				case '"', '`':
+					j = skipQuoted(sql, j, sql[j])
+					continue
+				case '[':
+					if k := strings.IndexByte(sql[j:], ']'); k >= 0 {
+						j += k + 1
+						continue
+					}
+				}
+				if !isWord(sql[j]) && sql[j] != '.' {
+					break
+				}
+				j++
+			}
+			if j == i {
+				j++
+			}
+			tokens = append(tokens, token{sql[i:j], depth})
+			i = j
+		default:
+			i++
+		}
+	}
+	return tokens
+}
+
+// skipQuoted returns the index after the closing quote of the quoted string which starts at i,
+// the doubled quote is the escape, the same as gorm explains the SQL.
+func skipQuoted(s string, i int, quote byte) int {
+	for i++; i < len(s); i++ {
+		if s[i] == quote {
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+func unquoteIdentifier(s string) string {
+	return strings.NewReplacer("`", "", `"`, "", "[", "", "]", "").Replace(s)
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isWord(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,79 @@
+package gormzap
+
+import (
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	var tests = []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "literals",
+			sql:  "SELECT * FROM `users` WHERE `users`.`id` = 10 AND name = 'bob''s' AND score > 1.5",
+			want: "SELECT * FROM `users` WHERE `users`.`id` = ? AND name = ? AND score > ?",
+		},
+		{
+			name: "in list",
+			sql:  "SELECT * FROM orders WHERE user_id IN (1,2, 3) AND t1.x = $1",
+			want: "SELECT * FROM orders WHERE user_id IN (?) AND t1.x = ?",
+		},
+		{
+			name: "rows",
+			sql:  "INSERT INTO `users` (`name`,`age`) VALUES ('a',1),('b',2)",
+			want: "INSERT INTO `users` (`name`,`age`) VALUES (?)",
+		},
+		{
+			name: "comments and whitespaces",
+			sql:  "/* trace */ SELECT  id\n\tFROM users -- note\n LIMIT 10",
+			want: "SELECT id FROM users LIMIT ?",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Fingerprint(tt.sql); got != tt.want {
+				t.Errorf("Fingerprint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFingerprintQuote(t *testing.T) {
+	sql := "SELECT * FROM `users` WHERE name = \"bob\"\"s\" AND note = 'x'"
+	if got, want := FingerprintQuote(sql, '"'), "SELECT * FROM `users` WHERE name = ? AND note = ?"; got != want {
+		t.Errorf("FingerprintQuote() = %q, want %q", got, want)
+	}
+	if got, want := Fingerprint(sql), "SELECT * FROM `users` WHERE name = \"bob\"\"s\" AND note = ?"; got != want {
+		t.Errorf("Fingerprint() = %q, want %q", got, want)
+	}
+	// the unknown dialect fails closed.
+	if got, want := FingerprintQuote(sql, 0), "SELECT * FROM `users` WHERE name = ? AND note = ?"; got != want {
+		t.Errorf("FingerprintQuote(0) = %q, want %q", got, want)
+	}
+}
+
+func TestParseStatement(t *testing.T) {
+	var tests = []struct {
+		sql       string
+		operation string
+		table     string
+	}{
+		{"SELECT count(*) FROM `users` WHERE id IN (SELECT user_id FROM orders)", "select", "users"},
+		{"select * from (select 1) t join `db`.`orders` o on 1 = 1", "select", "db.orders"},
+		{`INSERT INTO "public"."users" ("name") VALUES ('a')`, "insert", "public.users"},
+		{"UPDATE `users` SET `name`='from' WHERE `id` = 1", "update", "users"},
+		{"DELETE FROM [users] WHERE id = 1", "delete", "users"},
+		{"BEGIN", "begin", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			operation, table := ParseStatement(tt.sql)
+			if operation != tt.operation || table != tt.table {
+				t.Errorf("ParseStatement() = (%q, %q), want (%q, %q)", operation, table, tt.operation, tt.table)
+			}
+		})
+	}
+}
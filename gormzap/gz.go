@@ -10,7 +10,10 @@ import (
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 
+	"github.com/nova-clouds/restful-contrib/dbstats"
 	"github.com/nova-clouds/restful-contrib/internal/pool"
+	"github.com/nova-clouds/restful-contrib/subject"
+	"github.com/nova-clouds/restful-contrib/traceid"
 )
 
 // Logger logger for gorm2
@@ -19,6 +22,9 @@ type Logger struct {
 	logger.Config
 	customFields []func(ctx context.Context) zap.Field
 	callerCore   *CallerCore
+	dropLiterals bool // log the fingerprint instead of the SQL with the literal values
+	stringQuote  byte // the string quote of the dialect, 0 mean unknown
+	correlation  bool // log the trace id, the subject and the route of the request
 }
 
 // Option logger/recover option
@@ -38,6 +44,34 @@ func WithConfig(cfg logger.Config) Option {
 	}
 }
 
+// WithCorrelation optional log the "traceId", the "subject" and the "route" of the request
+// from the context, which are injected by the traceid.TraceId and the authj middlewares,
+// so the SQL logs join with the access logs. The empty value is omitted.
+func WithCorrelation(b bool) Option {
+	return func(l *Logger) {
+		l.correlation = b
+	}
+}
+
+// WithDropLiterals optional log the SQL fingerprint instead of the SQL with the literal values,
+// which avoids leaking the data into the logs. see FingerprintQuote.
+// NOTE: both the single and the double-quoted strings are dropped unless WithStringQuote is set,
+// set WithStringQuote('\”) to keep the double-quoted identifiers, like postgres.
+func WithDropLiterals(b bool) Option {
+	return func(l *Logger) {
+		l.dropLiterals = b
+	}
+}
+
+// WithStringQuote optional custom the string quote of the dialect, which is the escaper
+// the dialect explains the SQL with, like '"' of sqlite. see FingerprintQuote.
+// default: 0, the dialect is unknown, both the single and the double quote are string quotes
+func WithStringQuote(quote byte) Option {
+	return func(l *Logger) {
+		l.stringQuote = quote
+	}
+}
+
 // WithSkipPackages optional custom logger.Config
 func WithSkipPackages(skipPackages ...string) Option {
 	return func(l *Logger) {
@@ -45,7 +79,7 @@ func WithSkipPackages(skipPackages ...string) Option {
 	}
 }
 
-// WithConfig optional custom logger.Config
+// WithCallerCore optional custom the caller core.
 func WithCallerCore(c *CallerCore) Option {
 	return func(l *Logger) {
 		if c != nil {
@@ -74,6 +108,9 @@ func New(zapLogger *zap.Logger, opts ...Option) logger.Interface {
 	for _, opt := range opts {
 		opt(l)
 	}
+	if l.correlation {
+		l.customFields = append(l.customFields[:len(l.customFields):len(l.customFields)], TraceId, Subject, Route)
+	}
 	return l
 }
 
@@ -84,18 +121,27 @@ func (l *Logger) LogMode(level logger.LogLevel) logger.Interface {
 	return &newLogger
 }
 
+// logLevel returns the log level overridden in the context, or the configured log level.
+// the override only raises the verbosity, the level below the configured level is ignored.
+func (l *Logger) logLevel(ctx context.Context) logger.LogLevel {
+	if lv, ok := LogLevelFromContext(ctx); ok && lv > l.LogLevel {
+		return lv
+	}
+	return l.LogLevel
+}
+
 // Info print info
 func (l *Logger) Info(ctx context.Context, msg string, args ...any) {
-	if l.LogLevel >= logger.Info && l.log.Level().Enabled(zap.InfoLevel) {
+	if l.logLevel(ctx) >= logger.Info && l.log.Level().Enabled(zap.InfoLevel) {
 		msg = fmt.Sprintf(msg, args...)
-		if neeCaller := l.callerCore.caller != nil && l.callerCore.Enabled(zap.InfoLevel); neeCaller || len(l.customFields) > 0 {
+		if neeCaller := l.callerCore.Enabled(zap.InfoLevel); neeCaller || len(l.customFields) > 0 {
 			fc := pool.Get()
 			defer pool.Put(fc)
 			for _, customField := range l.customFields {
 				fc.Fields = append(fc.Fields, customField(ctx))
 			}
 			if neeCaller {
-				fc.Fields = append(fc.Fields, l.callerCore.caller(l.callerCore.skip, l.callerCore.skipPackages...))
+				fc.Fields = l.callerCore.appendCaller(fc.Fields, zap.InfoLevel)
 			}
 			l.log.Debug(msg, fc.Fields...)
 		} else {
@@ -106,16 +152,16 @@ func (l *Logger) Info(ctx context.Context, msg string, args ...any) {
 
 // Warn print warn messages
 func (l *Logger) Warn(ctx context.Context, msg string, args ...any) {
-	if l.LogLevel >= logger.Warn && l.log.Level().Enabled(zap.WarnLevel) {
+	if l.logLevel(ctx) >= logger.Warn && l.log.Level().Enabled(zap.WarnLevel) {
 		msg = fmt.Sprintf(msg, args...)
-		if neeCaller := l.callerCore.caller != nil && l.callerCore.Enabled(zap.WarnLevel); neeCaller || len(l.customFields) > 0 {
+		if neeCaller := l.callerCore.Enabled(zap.WarnLevel); neeCaller || len(l.customFields) > 0 {
 			fc := pool.Get()
 			defer pool.Put(fc)
 			for _, customField := range l.customFields {
 				fc.Fields = append(fc.Fields, customField(ctx))
 			}
 			if neeCaller {
-				fc.Fields = append(fc.Fields, l.callerCore.caller(l.callerCore.skip, l.callerCore.skipPackages...))
+				fc.Fields = l.callerCore.appendCaller(fc.Fields, zap.WarnLevel)
 			}
 			l.log.Warn(msg, fc.Fields...)
 		} else {
@@ -126,16 +172,16 @@ func (l *Logger) Warn(ctx context.Context, msg string, args ...any) {
 
 // Error print error messages
 func (l *Logger) Error(ctx context.Context, msg string, args ...any) {
-	if l.LogLevel >= logger.Error && l.log.Level().Enabled(zap.ErrorLevel) {
+	if l.logLevel(ctx) >= logger.Error && l.log.Level().Enabled(zap.ErrorLevel) {
 		msg = fmt.Sprintf(msg, args...)
-		if neeCaller := l.callerCore.caller != nil && l.callerCore.Enabled(zap.ErrorLevel); neeCaller || len(l.customFields) > 0 {
+		if neeCaller := l.callerCore.Enabled(zap.ErrorLevel); neeCaller || len(l.customFields) > 0 {
 			fc := pool.Get()
 			defer pool.Put(fc)
 			for _, customField := range l.customFields {
 				fc.Fields = append(fc.Fields, customField(ctx))
 			}
 			if neeCaller {
-				fc.Fields = append(fc.Fields, l.callerCore.caller(l.callerCore.skip, l.callerCore.skipPackages...))
+				fc.Fields = l.callerCore.appendCaller(fc.Fields, zap.ErrorLevel)
 			}
 			l.log.Error(msg, fc.Fields...)
 		} else {
@@ -144,16 +190,23 @@ func (l *Logger) Error(ctx context.Context, msg string, args ...any) {
 	}
 }
 
-// Trace print sql message
+// Trace print sql message,
+// the query is recorded into the per-request SQL statistics if it is carried in the context, see dbstats.
 func (l *Logger) Trace(ctx context.Context, begin time.Time, f func() (string, int64), err error) {
-	if l.LogLevel <= logger.Silent {
+	elapsed := time.Since(begin)
+	if stats := dbstats.FromContext(ctx); stats != nil {
+		sql, rows := f()
+		f = func() (string, int64) { return sql, rows }
+		stats.Record(FingerprintQuote(sql, l.stringQuote), elapsed)
+	}
+	logLevel := l.logLevel(ctx)
+	if logLevel <= logger.Silent {
 		return
 	}
 
-	elapsed := time.Since(begin)
 	switch {
 	case err != nil &&
-		l.LogLevel >= logger.Error &&
+		logLevel >= logger.Error &&
 		l.log.Level().Enabled(zap.ErrorLevel) &&
 		(!l.IgnoreRecordNotFoundError || !errors.Is(err, gorm.ErrRecordNotFound)):
 		fc := pool.Get()
@@ -165,21 +218,13 @@ func (l *Logger) Trace(ctx context.Context, begin time.Time, f func() (string, i
 			zap.Error(err),
 			zap.Duration("latency", elapsed),
 		)
-		if l.callerCore.caller != nil && l.callerCore.Enabled(zap.ErrorLevel) {
-			fc.Fields = append(fc.Fields, l.callerCore.caller(l.callerCore.skip, l.callerCore.skipPackages...))
-		}
+		fc.Fields = l.callerCore.appendCaller(fc.Fields, zap.ErrorLevel)
 
-		sql, rows := f()
-		if rows == -1 {
-			fc.Fields = append(fc.Fields, zap.String("rows", "-"))
-		} else {
-			fc.Fields = append(fc.Fields, zap.Int64("rows", rows))
-		}
-		fc.Fields = append(fc.Fields, zap.String("sql", sql))
+		fc.Fields = l.appendSQL(fc.Fields, f)
 		l.log.Error("trace", fc.Fields...)
 	case elapsed > l.SlowThreshold &&
 		l.SlowThreshold != 0 &&
-		l.LogLevel >= logger.Warn &&
+		logLevel >= logger.Warn &&
 		l.log.Level().Enabled(zap.WarnLevel):
 		fc := pool.Get()
 		defer pool.Put(fc)
@@ -187,44 +232,71 @@ func (l *Logger) Trace(ctx context.Context, begin time.Time, f func() (string, i
 			fc.Fields = append(fc.Fields, customField(ctx))
 		}
 		fc.Fields = append(fc.Fields, zap.Error(err))
-		if l.callerCore.caller != nil && l.callerCore.Enabled(zap.WarnLevel) {
-			fc.Fields = append(fc.Fields, l.callerCore.caller(l.callerCore.skip, l.callerCore.skipPackages...))
-		}
+		fc.Fields = l.callerCore.appendCaller(fc.Fields, zap.WarnLevel)
 		fc.Fields = append(fc.Fields,
 			zap.String("slow!!!", fmt.Sprintf("SLOW SQL >= %v", l.SlowThreshold)),
 			zap.Duration("latency", elapsed),
 		)
 
-		sql, rows := f()
-		if rows == -1 {
-			fc.Fields = append(fc.Fields, zap.String("rows", "-"))
-		} else {
-			fc.Fields = append(fc.Fields, zap.Int64("rows", rows))
-		}
-		fc.Fields = append(fc.Fields, zap.String("sql", sql))
+		fc.Fields = l.appendSQL(fc.Fields, f)
 		l.log.Warn("trace", fc.Fields...)
-	case l.LogLevel == logger.Info && l.log.Level().Enabled(zap.InfoLevel):
+	case logLevel == logger.Info && l.log.Level().Enabled(zap.InfoLevel):
 		fc := pool.Get()
 		defer pool.Put(fc)
 		for _, customField := range l.customFields {
 			fc.Fields = append(fc.Fields, customField(ctx))
 		}
 		fc.Fields = append(fc.Fields, zap.Error(err))
-		if l.callerCore.caller != nil && l.callerCore.Enabled(zap.InfoLevel) {
-			fc.Fields = append(fc.Fields, l.callerCore.caller(l.callerCore.skip, l.callerCore.skipPackages...))
-		}
+		fc.Fields = l.callerCore.appendCaller(fc.Fields, zap.InfoLevel)
 		fc.Fields = append(fc.Fields, zap.Duration("latency", elapsed))
-		sql, rows := f()
-		if rows == -1 {
-			fc.Fields = append(fc.Fields, zap.String("rows", "-"))
-		} else {
-			fc.Fields = append(fc.Fields, zap.Int64("rows", rows))
-		}
-		fc.Fields = append(fc.Fields, zap.String("sql", sql))
+		fc.Fields = l.appendSQL(fc.Fields, f)
 		l.log.Info("trace", fc.Fields...)
 	}
 }
 
+// appendSQL appends the rows, the SQL, the fingerprint, the table and the operation of the statement.
+func (l *Logger) appendSQL(fields []zap.Field, f func() (string, int64)) []zap.Field {
+	sql, rows := f()
+	if rows == -1 {
+		fields = append(fields, zap.String("rows", "-"))
+	} else {
+		fields = append(fields, zap.Int64("rows", rows))
+	}
+	fingerprint := FingerprintQuote(sql, l.stringQuote)
+	operation, table := ParseStatement(sql)
+	if l.dropLiterals {
+		sql = fingerprint
+	}
+	return append(fields,
+		zap.String("sql", sql),
+		zap.String("fingerprint", fingerprint),
+		zap.String("table", table),
+		zap.String("operation", operation),
+	)
+}
+
+// TraceId custom trace id field from the context, see traceid.FromTraceId.
+func TraceId(ctx context.Context) zap.Field {
+	return nonEmptyString("traceId", traceid.FromTraceId(ctx))
+}
+
+// Subject custom authenticated subject field from the context, see subject.FromSubject.
+func Subject(ctx context.Context) zap.Field {
+	return nonEmptyString("subject", subject.FromSubject(ctx))
+}
+
+// Route custom route template field from the context, see traceid.FromRoute.
+func Route(ctx context.Context) zap.Field {
+	return nonEmptyString("route", traceid.FromRoute(ctx))
+}
+
+func nonEmptyString(key, value string) zap.Field {
+	if value == "" {
+		return zap.Skip()
+	}
+	return zap.String(key, value)
+}
+
 // Immutable custom immutable field
 // Deprecated: use Any instead
 func Immutable(key string, value any) func(ctx context.Context) zap.Field {
@@ -0,0 +1,139 @@
+package gormzap
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"gorm.io/gorm/logger"
+
+	"github.com/nova-clouds/restful-contrib/authj"
+	"github.com/nova-clouds/restful-contrib/traceid"
+)
+
+func TestLoggerCorrelation(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	l := New(zap.New(core),
+		WithCorrelation(true),
+		WithConfig(logger.Config{LogLevel: logger.Info}),
+	)
+
+	r, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "/orders", nil)
+	req := restful.NewRequest(r)
+	req.Request = req.Request.WithContext(traceid.WithRoute(traceid.WithTraceId(req.Request.Context(), "t1"), "/orders"))
+	authj.ContextWithSubject(req, nil, "alice")
+
+	l.Trace(req.Request.Context(), time.Now(), func() (string, int64) { return "SELECT 1", 1 }, nil)
+	l.Trace(context.Background(), time.Now(), func() (string, int64) { return "SELECT 1", 1 }, nil)
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("supposed to be 2 log entries, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["traceId"] != "t1" || fields["subject"] != "alice" || fields["route"] != "/orders" {
+		t.Errorf("unexpected correlation fields: %v", fields)
+	}
+	fields = entries[1].ContextMap()
+	for _, key := range []string{"traceId", "subject", "route"} {
+		if _, ok := fields[key]; ok {
+			t.Errorf("empty %s should be omitted", key)
+		}
+	}
+}
+
+func TestLoggerDropLiterals(t *testing.T) {
+	sql := "SELECT * FROM \"users\" WHERE `email` = \"alice@example.com\" AND name = 'alice'"
+	var tests = []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"unknown dialect", nil, "SELECT * FROM ? WHERE `email` = ? AND name = ?"},
+		{"double quote", []Option{WithStringQuote('"')}, "SELECT * FROM ? WHERE `email` = ? AND name = ?"},
+		{"single quote", []Option{WithStringQuote('\'')}, "SELECT * FROM \"users\" WHERE `email` = \"alice@example.com\" AND name = ?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zap.InfoLevel)
+			l := New(zap.New(core), append([]Option{
+				WithDropLiterals(true),
+				WithConfig(logger.Config{LogLevel: logger.Info}),
+			}, tt.opts...)...)
+
+			l.Trace(context.Background(), time.Now(), func() (string, int64) { return sql, 1 }, nil)
+			if fields := logs.All()[0].ContextMap(); fields["sql"] != tt.want {
+				t.Errorf("sql = %v, want %v", fields["sql"], tt.want)
+			}
+		})
+	}
+}
+
+func TestLoggerLevelOverride(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	l := New(zap.New(core)) // default: logger.Warn
+
+	var ctxs []context.Context
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Filter(LevelOverride(
+		WithLevelAllow(nil), // ignored
+		WithLevelAllow(func(req *restful.Request) bool {
+			return req.Request.Header.Get("X-Admin") == "1"
+		}),
+	))
+	ws.Route(ws.GET("/orders").To(func(req *restful.Request, resp *restful.Response) {
+		ctxs = append(ctxs, req.Request.Context())
+	}))
+	router.Add(ws)
+	denied := LevelOverride()
+
+	var tests = []struct {
+		name   string
+		header map[string]string
+		err    error
+		traced bool
+	}{
+		{"no header", nil, nil, false},
+		{"not allowed", map[string]string{"X-Gorm-Log-Level": "info"}, nil, false},
+		{"invalid level", map[string]string{"X-Gorm-Log-Level": "verbose", "X-Admin": "1"}, nil, false},
+		{"override", map[string]string{"X-Gorm-Log-Level": "Info", "X-Admin": "1"}, nil, true},
+		{"lower level ignored", map[string]string{"X-Gorm-Log-Level": "silent", "X-Admin": "1"}, errors.New("oops"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctxs = ctxs[:0]
+			logs.TakeAll()
+			r, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "/orders", nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			router.ServeHTTP(httptest.NewRecorder(), r)
+			if len(ctxs) != 1 {
+				t.Fatalf("handler should be called once")
+			}
+			l.Trace(ctxs[0], time.Now(), func() (string, int64) { return "SELECT 1", 1 }, tt.err)
+			if got := logs.Len() == 1; got != tt.traced {
+				t.Errorf("traced = %v, want %v", got, tt.traced)
+			}
+		})
+	}
+
+	t.Run("default deny", func(t *testing.T) {
+		r, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "/orders", nil)
+		r.Header.Set("X-Gorm-Log-Level", "info")
+		req := restful.NewRequest(r)
+		chain := &restful.FilterChain{Target: func(req *restful.Request, resp *restful.Response) {
+			if _, ok := LogLevelFromContext(req.Request.Context()); ok {
+				t.Errorf("the log level should not be overridden by default")
+			}
+		}}
+		denied(req, restful.NewResponse(httptest.NewRecorder()), chain)
+	})
+}
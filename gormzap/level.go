@@ -0,0 +1,96 @@
+package gormzap
+
+import (
+	"context"
+	"strings"
+
+	"github.com/emicklei/go-restful/v3"
+	"gorm.io/gorm/logger"
+)
+
+// Key to use when setting the log level override.
+type ctxLogLevelKey struct{}
+
+// WithLogLevel returns a copy of the parent context which overrides the log level of the Logger,
+// like raise to logger.Info to trace all the SQL of one request.
+// It only raises the verbosity, the level below the configured level of the Logger is ignored.
+// NOTE: the zap logger must enable the corresponding level too.
+func WithLogLevel(ctx context.Context, level logger.LogLevel) context.Context {
+	return context.WithValue(ctx, ctxLogLevelKey{}, level)
+}
+
+// LogLevelFromContext returns the log level overridden in the context, and report whether it is present.
+func LogLevelFromContext(ctx context.Context) (logger.LogLevel, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	level, ok := ctx.Value(ctxLogLevelKey{}).(logger.LogLevel)
+	return level, ok
+}
+
+// ParseLogLevel parses the log level name, "silent", "error", "warn" or "info", case-insensitive.
+func ParseLogLevel(s string) (logger.LogLevel, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "silent":
+		return logger.Silent, true
+	case "error":
+		return logger.Error, true
+	case "warn", "warning":
+		return logger.Warn, true
+	case "info":
+		return logger.Info, true
+	default:
+		return 0, false
+	}
+}
+
+// LevelOption level override option
+type LevelOption func(c *levelConfig)
+
+type levelConfig struct {
+	header string
+	allow  func(req *restful.Request) bool
+}
+
+// WithLevelHeader optional custom the header which carries the log level name.
+// default: "X-Gorm-Log-Level"
+func WithLevelHeader(name string) LevelOption {
+	return func(c *levelConfig) {
+		if name != "" {
+			c.header = name
+		}
+	}
+}
+
+// WithLevelAllow optional custom which request is allowed to override the log level,
+// like only the authenticated administrators.
+// default: all requests are denied.
+func WithLevelAllow(f func(req *restful.Request) bool) LevelOption {
+	return func(c *levelConfig) {
+		if f != nil {
+			c.allow = f
+		}
+	}
+}
+
+// LevelOverride returns a filter which overrides the log level of the Logger for the request
+// with the log level name in the header, see ParseLogLevel, the invalid name is ignored.
+// The queries must use the request context, like db.WithContext(req.Request.Context()).
+// NOTE: all requests are denied unless allowed by WithLevelAllow, as the SQL may contain sensitive data.
+func LevelOverride(opts ...LevelOption) restful.FilterFunction {
+	cfg := levelConfig{
+		header: "X-Gorm-Log-Level",
+		allow:  func(req *restful.Request) bool { return false },
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		if v := req.Request.Header.Get(cfg.header); v != "" {
+			if level, ok := ParseLogLevel(v); ok && cfg.allow(req) {
+				req.Request = req.Request.WithContext(WithLogLevel(req.Request.Context(), level))
+			}
+		}
+		chain.ProcessFilter(req, resp)
+	}
+}
@@ -1,6 +1,7 @@
 package gormzap
 
 import (
+	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
@@ -10,23 +11,30 @@ import (
 )
 
 var (
-	gormPackage    = "gorm.io/gorm"
-	gormzapPackage = "github.com/things-go/gin-contrib/gormzap"
+	gormPackage = "gorm.io/gorm"
+	// gormzapPackage the package path of this package, derived at runtime.
+	gormzapPackage = reflect.TypeOf(Logger{}).PkgPath()
 )
 
+// CallerCore resolves the caller of the SQL, which is the first frame outside gorm,
+// this package and the skip packages.
+// The zero value uses the info level and the default depth, prefer NewCallerCore.
 type CallerCore struct {
 	level        zap.AtomicLevel
 	skip         int
+	depth        int
 	skipPackages []string
-	caller       func(depth int, skipPackages ...string) zap.Field
+	function     bool // add the function name
+	zapCaller    bool // use the zap.AddCaller compatible fields
 }
 
+// NewCallerCore new a caller core.
 func NewCallerCore() *CallerCore {
 	return &CallerCore{
 		level:        zap.NewAtomicLevelAt(zap.InfoLevel),
 		skip:         2,
+		depth:        64,
 		skipPackages: nil,
-		caller:       callerFile,
 	}
 }
 
@@ -36,26 +44,57 @@ func (c *CallerCore) AddSkip(callerSkip int) *CallerCore {
 	return c
 }
 
-// AddSkipPackage add the caller skip package.
+// AddSkipPackage add the caller skip package, it matches the package path prefix
+// of the function or the file path of the frame.
 func (c *CallerCore) AddSkipPackage(vs ...string) *CallerCore {
 	c.skipPackages = append(c.skipPackages, vs...)
 	return c
 }
 
+// SetDepth set the max frames to scan for the caller.
+// default: 64
+func (c *CallerCore) SetDepth(depth int) *CallerCore {
+	if depth > 0 {
+		c.depth = depth
+	}
+	return c
+}
+
+// SetFunction set whether to add the "function" field with the function name of the caller.
+func (c *CallerCore) SetFunction(b bool) *CallerCore {
+	c.function = b
+	return c
+}
+
+// UseZapCaller set whether to use the zap.AddCaller compatible field "caller" like "gormzap/gz.go:42",
+// instead of the "file" field with the full path.
+func (c *CallerCore) UseZapCaller(b bool) *CallerCore {
+	c.zapCaller = b
+	return c
+}
+
 // SetLevel set the caller level.
 func (c *CallerCore) SetLevel(lv zapcore.Level) *CallerCore {
-	c.level.SetLevel(lv)
+	if c.level == (zap.AtomicLevel{}) {
+		c.level = zap.NewAtomicLevelAt(lv)
+	} else {
+		c.level.SetLevel(lv)
+	}
 	return c
 }
 
 // Level returns the minimum enabled log level.
 func (c *CallerCore) Level() zapcore.Level {
+	// the zero AtomicLevel is not initialized.
+	if c.level == (zap.AtomicLevel{}) {
+		return zap.InfoLevel
+	}
 	return c.level.Level()
 }
 
 // Enabled returns true if the given level is at or above this level.
 func (c *CallerCore) Enabled(lvl zapcore.Level) bool {
-	return c.level.Enabled(lvl)
+	return c != nil && c.Level().Enabled(lvl)
 }
 
 // UseExternalLevel use external level, which controller by user.
@@ -64,33 +103,79 @@ func (c *CallerCore) UseExternalLevel(l zap.AtomicLevel) *CallerCore {
 	return c
 }
 
-// callerFile caller file.
-func callerFile(depth int, skipPackages ...string) zap.Field {
-	var file string
-	var line int
-	var ok bool
-
-	for i := depth; i < depth+15; i++ {
-		_, file, line, ok = runtime.Caller(i)
-		if ok && !skipPackage(file, skipPackages...) {
-			break
+// appendCaller appends the caller fields if the level is enabled.
+func (c *CallerCore) appendCaller(fields []zap.Field, lvl zapcore.Level) []zap.Field {
+	if !c.Enabled(lvl) {
+		return fields
+	}
+	frame, ok := c.caller()
+	if !ok {
+		return fields
+	}
+	if c.zapCaller {
+		ec := zapcore.EntryCaller{
+			Defined:  true,
+			PC:       frame.PC,
+			File:     frame.File,
+			Line:     frame.Line,
+			Function: frame.Function,
 		}
+		fields = append(fields, zap.String("caller", ec.TrimmedPath()))
+	} else {
+		fields = append(fields, zap.String("file", frame.File+":"+strconv.Itoa(frame.Line)))
+	}
+	if c.function {
+		fields = append(fields, zap.String("function", frame.Function))
 	}
-	return zap.String("file", file+":"+strconv.FormatInt(int64(line), 10))
+	return fields
 }
 
-func skipPackage(file string, skipPackages ...string) bool {
-	if strings.HasSuffix(file, "_test.go") {
-		return false
+// caller returns the first frame which is not skipped.
+func (c *CallerCore) caller() (runtime.Frame, bool) {
+	depth := c.depth
+	if depth <= 0 {
+		depth = 64
 	}
-	if strings.Contains(file, gormPackage) ||
-		strings.Contains(file, gormzapPackage) {
-		return true
+	pcs := make([]uintptr, depth)
+	// skip runtime.Callers and caller, the default skip skips appendCaller and the logger method.
+	n := runtime.Callers(c.skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if frame.PC != 0 && !c.skipFrame(frame) {
+			return frame, true
+		}
+		if !more {
+			return runtime.Frame{}, false
+		}
 	}
-	for _, p := range skipPackages {
-		if strings.Contains(file, p) {
+}
+
+func (c *CallerCore) skipFrame(frame runtime.Frame) bool {
+	pkg := funcPackage(frame.Function)
+	for _, p := range c.skipPackages {
+		if inPackage(pkg, p) || strings.Contains(frame.File, p) {
 			return true
 		}
 	}
-	return false
+	// the tests of gorm and this package are the application code.
+	if strings.HasSuffix(frame.File, "_test.go") {
+		return false
+	}
+	return inPackage(pkg, gormPackage) || pkg == gormzapPackage
+}
+
+// funcPackage returns the package path of the function name,
+// like "github.com/a/b.(*T).M" -> "github.com/a/b".
+func funcPackage(function string) string {
+	i := strings.LastIndexByte(function, '/')
+	if j := strings.IndexByte(function[i+1:], '.'); j >= 0 {
+		return function[:i+1+j]
+	}
+	return function
+}
+
+// inPackage report whether the package is the parent package or its sub package.
+func inPackage(pkg, parent string) bool {
+	return pkg == parent || strings.HasPrefix(pkg, parent+"/")
 }
@@ -0,0 +1,124 @@
+package gormzap
+
+import (
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/utils/tests"
+)
+
+type user struct {
+	ID   uint
+	Name string
+}
+
+func newTestDB(t *testing.T, c *CallerCore) (*gorm.DB, *observer.ObservedLogs) {
+	t.Helper()
+	core, logs := observer.New(zap.InfoLevel)
+	l := New(zap.New(core),
+		WithConfig(logger.Config{LogLevel: logger.Info}),
+		WithCallerCore(c),
+	)
+	db, err := gorm.Open(tests.DummyDialector{}, &gorm.Config{DryRun: true, Logger: l})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, logs
+}
+
+// findUsers is the application code which queries.
+func findUsers(db *gorm.DB) (file string, line int) {
+	var users []user
+	_, file, line, _ = runtime.Caller(0)
+	db.Where("name = ?", "bob").Find(&users)
+	return file, line + 1
+}
+
+func TestCallerCore(t *testing.T) {
+	if gormzapPackage != "github.com/nova-clouds/restful-contrib/gormzap" {
+		t.Fatalf("gormzapPackage = %s", gormzapPackage)
+	}
+
+	t.Run("file", func(t *testing.T) {
+		db, logs := newTestDB(t, NewCallerCore())
+		file, line := findUsers(db)
+
+		entries := logs.All()
+		if len(entries) != 1 {
+			t.Fatalf("supposed to be 1 log entry, got %d", len(entries))
+		}
+		fields := entries[0].ContextMap()
+		if want := file + ":" + strconv.Itoa(line); fields["file"] != want {
+			t.Errorf("file = %v, want %v", fields["file"], want)
+		}
+		if _, ok := fields["function"]; ok {
+			t.Errorf("function should not be present")
+		}
+	})
+	t.Run("zap caller and function", func(t *testing.T) {
+		db, logs := newTestDB(t, NewCallerCore().UseZapCaller(true).SetFunction(true))
+		file, line := findUsers(db)
+
+		fields := logs.All()[0].ContextMap()
+		want := filepath.Base(filepath.Dir(file)) + "/" + filepath.Base(file) + ":" + strconv.Itoa(line)
+		if fields["caller"] != want {
+			t.Errorf("caller = %v, want %v", fields["caller"], want)
+		}
+		if fields["function"] != gormzapPackage+".findUsers" {
+			t.Errorf("function = %v, want %v", fields["function"], gormzapPackage+".findUsers")
+		}
+	})
+	t.Run("skip package", func(t *testing.T) {
+		db, logs := newTestDB(t, NewCallerCore().AddSkipPackage("gormzap/utils_test.go"))
+		findUsers(db)
+
+		fields := logs.All()[0].ContextMap()
+		if file, _ := fields["file"].(string); file == "" || strings.Contains(file, "gormzap/utils_test.go") {
+			t.Errorf("file = %v, the skip package should be skipped", file)
+		}
+	})
+	t.Run("zero value", func(t *testing.T) {
+		db, logs := newTestDB(t, &CallerCore{})
+		file, line := findUsers(db)
+
+		fields := logs.All()[0].ContextMap()
+		if want := file + ":" + strconv.Itoa(line); fields["file"] != want {
+			t.Errorf("file = %v, want %v", fields["file"], want)
+		}
+		if c := (&CallerCore{}).SetLevel(zap.WarnLevel); c.Enabled(zap.InfoLevel) || !c.Enabled(zap.WarnLevel) {
+			t.Errorf("SetLevel() of the zero value should take effect")
+		}
+	})
+	t.Run("depth", func(t *testing.T) {
+		db, logs := newTestDB(t, NewCallerCore().SetDepth(1))
+		findUsers(db)
+
+		if _, ok := logs.All()[0].ContextMap()["file"]; ok {
+			t.Errorf("file should not be present as the depth is too small")
+		}
+	})
+}
+
+func TestFuncPackage(t *testing.T) {
+	var tests = []struct {
+		function string
+		want     string
+	}{
+		{"github.com/a/b.(*T).M", "github.com/a/b"},
+		{"github.com/a/b.F.func1", "github.com/a/b"},
+		{"gorm.io/gorm.(*DB).Find", "gorm.io/gorm"},
+		{"main.main", "main"},
+	}
+	for _, tt := range tests {
+		if got := funcPackage(tt.function); got != tt.want {
+			t.Errorf("funcPackage(%s) = %s, want %s", tt.function, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package gzap
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// AsyncPolicy the policy when the queue of the async core is full.
+type AsyncPolicy int
+
+const (
+	// AsyncDropOldest drops the oldest entry in the queue, the request path never stalls.
+	AsyncDropOldest AsyncPolicy = iota
+	// AsyncBlock blocks the writer until the queue has room, no entry is lost.
+	AsyncBlock
+)
+
+// AsyncOption async core option
+type AsyncOption func(q *asyncQueue)
+
+// WithAsyncQueueSize optional custom the max entries of the queue.
+// default: 4096
+func WithAsyncQueueSize(size int) AsyncOption {
+	return func(q *asyncQueue) {
+		if size > 0 {
+			q.buf = make([]asyncEntry, size)
+		}
+	}
+}
+
+// WithAsyncPolicy optional custom the policy when the queue is full.
+// default: AsyncDropOldest
+func WithAsyncPolicy(policy AsyncPolicy) AsyncOption {
+	return func(q *asyncQueue) {
+		q.policy = policy
+	}
+}
+
+// AsyncCore is a zapcore.Core which writes the entries to the wrapped core
+// asynchronously through a bounded in-memory queue.
+// Sync drains the queue, Close drains the queue and stops the background goroutine,
+// call it on shutdown. The entries above ErrorLevel are written synchronously.
+// use like:
+//
+//	async := gzap.NewAsyncCore(logger.Core())
+//	defer async.Close()
+//	ws.Filter(gzap.Logger(zap.New(async)))
+type AsyncCore struct {
+	core zapcore.Core
+	q    *asyncQueue
+}
+
+// NewAsyncCore new an async core which wraps the core.
+func NewAsyncCore(core zapcore.Core, opts ...AsyncOption) *AsyncCore {
+	q := &asyncQueue{
+		buf:  make([]asyncEntry, 4096),
+		done: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	q.notEmpty = sync.NewCond(&q.mu)
+	q.notFull = sync.NewCond(&q.mu)
+	q.idle = sync.NewCond(&q.mu)
+	go q.run()
+	return &AsyncCore{core: core, q: q}
+}
+
+// Enabled implements zapcore.LevelEnabler.
+func (c *AsyncCore) Enabled(lv zapcore.Level) bool { return c.core.Enabled(lv) }
+
+// Level returns the minimum enabled level of the wrapped core.
+func (c *AsyncCore) Level() zapcore.Level { return zapcore.LevelOf(c.core) }
+
+// With implements zapcore.Core, the returned core shares the queue.
+func (c *AsyncCore) With(fields []zapcore.Field) zapcore.Core {
+	return &AsyncCore{core: c.core.With(fields), q: c.q}
+}
+
+// Check implements zapcore.Core.
+func (c *AsyncCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write implements zapcore.Core, it copies the fields as the caller may reuse them.
+func (c *AsyncCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.Level > zapcore.ErrorLevel {
+		// the process may exit after writing, so drain the queue and write directly.
+		c.q.flush()
+		return c.core.Write(ent, fields)
+	}
+	if !c.q.push(asyncEntry{c.core, ent, append([]zapcore.Field(nil), fields...)}) {
+		return c.core.Write(ent, fields)
+	}
+	return nil
+}
+
+// Sync implements zapcore.Core, it drains the queue and syncs the wrapped core.
+func (c *AsyncCore) Sync() error {
+	c.q.flush()
+	return c.core.Sync()
+}
+
+// Close drains the queue and stops the background goroutine, then syncs the wrapped core.
+// The entries written after closing are written synchronously.
+func (c *AsyncCore) Close() error {
+	c.q.close()
+	return c.core.Sync()
+}
+
+// Dropped returns the number of the entries dropped as the queue is full.
+func (c *AsyncCore) Dropped() uint64 { return c.q.dropped.Load() }
+
+// Failed returns the number of the entries which the wrapped core failed to write.
+func (c *AsyncCore) Failed() uint64 { return c.q.failed.Load() }
+
+// Pending returns the number of the entries in the queue.
+func (c *AsyncCore) Pending() int {
+	c.q.mu.Lock()
+	defer c.q.mu.Unlock()
+	return c.q.n
+}
+
+type asyncEntry struct {
+	core   zapcore.Core
+	ent    zapcore.Entry
+	fields []zapcore.Field
+}
+
+// asyncQueue a bounded ring queue with a single consumer.
+type asyncQueue struct {
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	idle     *sync.Cond
+	buf      []asyncEntry
+	head, n  int
+	busy     bool // the consumer is writing an entry
+	closed   bool
+	policy   AsyncPolicy
+	dropped  atomic.Uint64
+	failed   atomic.Uint64
+	done     chan struct{}
+}
+
+// push pushes the entry, it returns false if the queue is closed.
+func (q *asyncQueue) push(e asyncEntry) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for !q.closed && q.n == len(q.buf) {
+		if q.policy == AsyncBlock {
+			q.notFull.Wait()
+			continue
+		}
+		q.buf[q.head] = asyncEntry{}
+		q.head = (q.head + 1) % len(q.buf)
+		q.n--
+		q.dropped.Add(1)
+	}
+	if q.closed {
+		return false
+	}
+	q.buf[(q.head+q.n)%len(q.buf)] = e
+	q.n++
+	q.notEmpty.Signal()
+	return true
+}
+
+func (q *asyncQueue) run() {
+	defer close(q.done)
+	for {
+		q.mu.Lock()
+		for q.n == 0 && !q.closed {
+			q.notEmpty.Wait()
+		}
+		if q.n == 0 {
+			q.mu.Unlock()
+			return
+		}
+		e := q.buf[q.head]
+		q.buf[q.head] = asyncEntry{}
+		q.head = (q.head + 1) % len(q.buf)
+		q.n--
+		q.busy = true
+		q.notFull.Signal()
+		q.mu.Unlock()
+
+		if err := e.core.Write(e.ent, e.fields); err != nil {
+			q.failed.Add(1)
+		}
+
+		q.mu.Lock()
+		q.busy = false
+		if q.n == 0 {
+			q.idle.Broadcast()
+		}
+		q.mu.Unlock()
+	}
+}
+
+// flush waits until the queue is drained.
+func (q *asyncQueue) flush() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.n > 0 || q.busy {
+		q.idle.Wait()
+	}
+}
+
+func (q *asyncQueue) close() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		q.notEmpty.Broadcast()
+		q.notFull.Broadcast()
+		q.idle.Broadcast()
+	}
+	q.mu.Unlock()
+	<-q.done
+}
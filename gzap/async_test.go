@@ -0,0 +1,103 @@
+package gzap
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// gateCore blocks writing until the gate is opened.
+type gateCore struct {
+	zapcore.Core
+	gate chan struct{}
+}
+
+func (c *gateCore) With(fields []zapcore.Field) zapcore.Core {
+	return &gateCore{c.Core.With(fields), c.gate}
+}
+
+func (c *gateCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	<-c.gate
+	return c.Core.Write(ent, fields)
+}
+
+func waitPending(t *testing.T, c *AsyncCore, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); c.Pending() != n; {
+		if time.Now().After(deadline) {
+			t.Fatalf("pending = %d, want %d", c.Pending(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAsyncCore(t *testing.T) {
+	t.Run("drop oldest", func(t *testing.T) {
+		obs, logs := observer.New(zap.InfoLevel)
+		gate := make(chan struct{})
+		async := NewAsyncCore(&gateCore{obs, gate}, WithAsyncQueueSize(2))
+		logger := zap.New(async).With(zap.String("app", "test"))
+
+		logger.Info("0")
+		waitPending(t, async, 0) // the consumer is blocked on writing "0"
+		for _, msg := range []string{"1", "2", "3"} {
+			logger.Info(msg, zap.String("msg", msg))
+		}
+		if got := async.Dropped(); got != 1 {
+			t.Errorf("Dropped() = %d, want 1", got)
+		}
+		close(gate)
+		if err := async.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, e := range logs.All() {
+			got = append(got, e.Message)
+			if e.ContextMap()["app"] != "test" {
+				t.Errorf("the fields of With should be kept, got %v", e.ContextMap())
+			}
+		}
+		if want := []string{"0", "2", "3"}; !slices.Equal(got, want) {
+			t.Errorf("messages = %v, want %v", got, want)
+		}
+
+		logger.Info("after close")
+		if logs.Len() != 4 {
+			t.Errorf("the entry after closing should be written synchronously")
+		}
+	})
+	t.Run("block", func(t *testing.T) {
+		obs, logs := observer.New(zap.InfoLevel)
+		gate := make(chan struct{})
+		async := NewAsyncCore(&gateCore{obs, gate}, WithAsyncQueueSize(1), WithAsyncPolicy(AsyncBlock))
+		logger := zap.New(async)
+
+		logger.Info("0")
+		waitPending(t, async, 0)
+		logger.Info("1")
+		done := make(chan struct{})
+		go func() {
+			logger.Info("2")
+			close(done)
+		}()
+		select {
+		case <-done:
+			t.Fatal("writing should block as the queue is full")
+		case <-time.After(20 * time.Millisecond):
+		}
+		close(gate)
+		<-done
+		if err := logger.Sync(); err != nil {
+			t.Fatal(err)
+		}
+		if logs.Len() != 3 || async.Dropped() != 0 {
+			t.Errorf("entries = %d, dropped = %d", logs.Len(), async.Dropped())
+		}
+		_ = async.Close()
+	})
+}
@@ -0,0 +1,163 @@
+package gzap
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// BodyKind the kind of the body to log.
+type BodyKind int
+
+const (
+	// BodyText logs the body as a string.
+	BodyText BodyKind = iota
+	// BodyJSON logs the body as a structured object,
+	// it falls back to BodyText if the body is truncated or invalid.
+	BodyJSON
+	// BodyBinary logs the summary of the body, the size and the content type only.
+	BodyBinary
+)
+
+// ContentKind returns the body kind of the content type.
+//   - BodyJSON: application/json, application/*+json.
+//   - BodyText: text/*, application/xml, application/*+xml, application/x-www-form-urlencoded,
+//     application/javascript, or no content type.
+//   - BodyBinary: otherwise, like image/*, application/octet-stream, application/x-protobuf.
+func ContentKind(contentType string) BodyKind {
+	if contentType == "" {
+		return BodyText
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return BodyBinary
+	}
+	switch {
+	case isJSON(mediaType):
+		return BodyJSON
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/xml",
+		strings.HasSuffix(mediaType, "+xml"),
+		mediaType == "application/x-www-form-urlencoded",
+		mediaType == "application/javascript":
+		return BodyText
+	default:
+		return BodyBinary
+	}
+}
+
+// appendBody appends the captured body field by the body kind of the content type,
+// if the body is truncated, the truncated flag and the size on the wire are appended too.
+// The decompressed body always appends the size on the wire and the decoded length of the content.
+func (c *Config) appendBody(fields []zap.Field, keys bodyKeys, header http.Header, b *limitBuffer) []zap.Field {
+	contentType := header.Get("Content-Type")
+	body := b.Bytes()
+	truncated := b.Truncated()
+	decoded := false
+
+	if encoding := header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		var err error
+
+		if !c.decompressBody {
+			return append(fields, bodySummary(keys.summary, contentType, encoding, b.Size()))
+		}
+		body, truncated, err = decompress(encoding, body, truncated, b.limit)
+		if err != nil {
+			return append(fields, bodySummary(keys.summary, contentType, encoding, b.Size()))
+		}
+		decoded = true
+	}
+
+	switch c.bodyKind(contentType) {
+	case BodyBinary:
+		return append(fields, bodySummary(keys.summary, contentType, "", b.Size()))
+	case BodyJSON:
+		if keys.stringContent {
+			fields = append(fields, zap.String(keys.content, c.redactBody(contentType, body)))
+			break
+		}
+		if v, ok := c.decodeJSON(contentType, body, truncated); ok {
+			fields = append(fields, zap.Any(keys.content, v))
+			break
+		}
+		fields = append(fields, zap.String(keys.content, c.redactBody(contentType, body)))
+	default:
+		fields = append(fields, zap.String(keys.content, c.redactBody(contentType, body)))
+	}
+	if truncated {
+		fields = append(fields, zap.Bool(keys.truncated, true))
+	}
+	if truncated || decoded {
+		fields = append(fields, zap.Int64(keys.size, b.Size()))
+	}
+	if decoded {
+		fields = append(fields, zap.Int(keys.decodedSize, len(body)))
+	}
+	return fields
+}
+
+func (c *Config) decodeJSON(contentType string, body []byte, truncated bool) (any, bool) {
+	var v any
+
+	if truncated || len(body) == 0 {
+		return nil, false
+	}
+	d := json.NewDecoder(strings.NewReader(c.redactBody(contentType, body)))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+// maxDecompressedBody the max decompressed bytes when the capture is not limited.
+const maxDecompressedBody = 1 << 20
+
+// decompress decompresses the captured body, at most limit bytes are inflated,
+// limit <=0 mean maxDecompressedBody. the truncated body is decompressed as much as possible.
+func decompress(encoding string, body []byte, truncated bool, limit int) ([]byte, bool, error) {
+	var r io.Reader
+	var err error
+
+	switch strings.ToLower(encoding) {
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		r, err = zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			r, err = flate.NewReader(bytes.NewReader(body)), nil
+		}
+	default:
+		return nil, false, errors.New("unsupported content encoding")
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if limit <= 0 {
+		limit = maxDecompressedBody
+	}
+	out := newLimitBuffer(limit)
+	// one more byte to report the truncation, the rest of the stream is never inflated.
+	_, err = io.Copy(out, io.LimitReader(r, int64(limit)+1))
+	if err != nil && (!truncated || !errors.Is(err, io.ErrUnexpectedEOF)) {
+		return nil, false, err
+	}
+	return out.Bytes(), truncated || out.Truncated(), nil
+}
+
+func bodySummary(key, contentType, encoding string, size int64) zap.Field {
+	return zap.Dict(key,
+		zap.String("contentType", contentType),
+		zap.String("contentEncoding", encoding),
+		zap.Int64("size", size),
+	)
+}
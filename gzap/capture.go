@@ -0,0 +1,88 @@
+package gzap
+
+import (
+	"bytes"
+	"io"
+)
+
+// limitBuffer is a writer which keeps at most limit bytes, and counts the real size.
+type limitBuffer struct {
+	buf   bytes.Buffer
+	limit int   // <=0: mean not limit
+	size  int64 // the real size written
+}
+
+func newLimitBuffer(limit int) *limitBuffer {
+	return &limitBuffer{limit: limit}
+}
+
+// Write always consumes the whole p, it never returns an error.
+func (b *limitBuffer) Write(p []byte) (int, error) {
+	b.size += int64(len(p))
+	if b.limit <= 0 {
+		return b.buf.Write(p)
+	}
+	if remain := b.limit - b.buf.Len(); remain > 0 {
+		if len(p) > remain {
+			b.buf.Write(p[:remain])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// WriteString always consumes the whole s, it never returns an error.
+func (b *limitBuffer) WriteString(s string) (int, error) {
+	b.size += int64(len(s))
+	if b.limit <= 0 {
+		return b.buf.WriteString(s)
+	}
+	if remain := b.limit - b.buf.Len(); remain > 0 {
+		if len(s) > remain {
+			b.buf.WriteString(s[:remain])
+		} else {
+			b.buf.WriteString(s)
+		}
+	}
+	return len(s), nil
+}
+
+// Bytes returns the captured bytes.
+func (b *limitBuffer) Bytes() []byte { return b.buf.Bytes() }
+
+// Size returns the real size written.
+func (b *limitBuffer) Size() int64 { return b.size }
+
+// Truncated reports whether the captured bytes are less than the real size.
+func (b *limitBuffer) Truncated() bool { return int64(b.buf.Len()) < b.size }
+
+// teeReadCloser captures the bytes lazily when the body is read.
+type teeReadCloser struct {
+	rc io.ReadCloser
+	w  *limitBuffer
+}
+
+func (t *teeReadCloser) Read(p []byte) (int, error) {
+	n, err := t.rc.Read(p)
+	if n > 0 {
+		t.w.Write(p[:n]) // nolint: errcheck
+	}
+	return n, err
+}
+
+func (t *teeReadCloser) Close() error { return t.rc.Close() }
+
+// countReadCloser counts the bytes read.
+type countReadCloser struct {
+	rc io.ReadCloser
+	n  int64
+}
+
+func (c *countReadCloser) Read(p []byte) (int, error) {
+	n, err := c.rc.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countReadCloser) Close() error { return c.rc.Close() }
@@ -0,0 +1,101 @@
+package gzap
+
+import (
+	"context"
+	"sync"
+
+	"github.com/emicklei/go-restful/v3"
+	"go.uber.org/zap"
+
+	"github.com/nova-clouds/restful-contrib/subject"
+	"github.com/nova-clouds/restful-contrib/traceid"
+)
+
+// ctxLoggerKey is the key of the request-scoped logger.
+type ctxLoggerKey struct{}
+
+// WithContextLogger optional put a request-scoped child logger into the request context,
+// which has the "traceId", "route", "method" and "subject" fields attached,
+// use FromContext to get it.
+// default: false
+func WithContextLogger(b bool) Option {
+	return func(c *Config) {
+		c.contextLogger = b
+	}
+}
+
+// WithContextSubject optional custom the authenticated subject extractor of the request-scoped logger.
+// default: subject.FromSubject of the request context
+func WithContextSubject(f func(req *restful.Request) string) Option {
+	return func(c *Config) {
+		if f != nil {
+			c.contextSubject = f
+		}
+	}
+}
+
+func contextSubject(req *restful.Request) string {
+	return subject.FromSubject(req.Request.Context())
+}
+
+// requestLogger is the request-scoped logger, the fields are resolved lazily,
+// so the trace id and the subject set by the later filters are attached too.
+type requestLogger struct {
+	base    *zap.Logger
+	req     *restful.Request
+	subject func(req *restful.Request) string
+
+	mu      sync.Mutex
+	traceId string
+	sub     string
+	logger  *zap.Logger
+}
+
+func (l *requestLogger) Logger() *zap.Logger {
+	traceId := traceid.FromTraceId(l.req.Request.Context())
+	sub := l.subject(l.req)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.logger == nil || l.traceId != traceId || l.sub != sub {
+		route := ""
+		if r := l.req.SelectedRoute(); r != nil {
+			route = r.Path()
+		}
+		l.traceId, l.sub = traceId, sub
+		l.logger = l.base.With(
+			zap.String("traceId", traceId),
+			zap.String("route", route),
+			zap.String("method", l.req.Request.Method),
+			zap.String("subject", sub),
+		)
+	}
+	return l.logger
+}
+
+// NewContext put the request-scoped logger into the request context,
+// and the route template if it is absent, see traceid.FromRoute.
+func NewContext(req *restful.Request, logger *zap.Logger, subject func(req *restful.Request) string) {
+	if subject == nil {
+		subject = contextSubject
+	}
+	ctx := req.Request.Context()
+	if r := req.SelectedRoute(); r != nil && traceid.FromRoute(ctx) == "" {
+		ctx = traceid.WithRoute(ctx, r.Path())
+	}
+	ctx = context.WithValue(ctx, ctxLoggerKey{}, &requestLogger{
+		base:    logger,
+		req:     req,
+		subject: subject,
+	})
+	req.Request = req.Request.WithContext(ctx)
+}
+
+// FromContext returns the request-scoped logger from the context,
+// it returns the global logger zap.L() if not present.
+func FromContext(ctx context.Context) *zap.Logger {
+	if l, ok := ctx.Value(ctxLoggerKey{}).(*requestLogger); ok {
+		return l.Logger()
+	}
+	return zap.L()
+}
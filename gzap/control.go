@@ -0,0 +1,395 @@
+package gzap
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"go.uber.org/zap/zapcore"
+)
+
+// Control controls the logging at runtime, every change expires automatically.
+// It is safe for concurrent use.
+type Control struct {
+	mu         sync.RWMutex
+	level      *expirable[zapcore.Level]
+	enableBody *expirable[bool]
+	debugCurls map[string]time.Time // "<method> <route>" -> expire at
+	sessions   []*DebugSession
+}
+
+type expirable[T any] struct {
+	value    T
+	expireAt time.Time
+}
+
+func (e *expirable[T]) active(now time.Time) bool {
+	return e != nil && now.Before(e.expireAt)
+}
+
+// DebugSession captures everything for the requests matching the header or the subject
+// until it expires.
+type DebugSession struct {
+	// Id the session id.
+	Id string `json:"id"`
+	// Header the header name, matches the request which has the header with Value.
+	Header string `json:"header,omitempty"`
+	// Value the header value, empty matches any value.
+	Value string `json:"value,omitempty"`
+	// Subject matches the request with the authenticated subject.
+	Subject string `json:"subject,omitempty"`
+	// ExpireAt the expire time of the session.
+	ExpireAt time.Time `json:"expireAt"`
+}
+
+func (s *DebugSession) matchHeader(req *restful.Request) bool {
+	if s.Header == "" {
+		return false
+	}
+	vs := req.Request.Header.Values(s.Header)
+	return len(vs) > 0 && (s.Value == "" || slices.Contains(vs, s.Value))
+}
+
+// NewControl new a control.
+func NewControl() *Control {
+	return &Control{
+		debugCurls: make(map[string]time.Time),
+	}
+}
+
+var errControlTTL = errors.New("gzap: control change requires a positive ttl")
+
+// SetLevel set the minimum level of the access log until the ttl expires, ttl must be positive.
+// It filters the entries before they reach the logger, so it can only raise the level of the logger,
+// a level below the logger core level, like DebugLevel on a logger built at InfoLevel, has no effect,
+// use a zap.AtomicLevel for the logger to lower it.
+func (c *Control) SetLevel(lv zapcore.Level, ttl time.Duration) error {
+	if ttl <= 0 {
+		return errControlTTL
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.level = &expirable[zapcore.Level]{lv, time.Now().Add(ttl)}
+	return nil
+}
+
+// ResetLevel reset the minimum level of the access log.
+func (c *Control) ResetLevel() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.level = nil
+}
+
+// Level returns the minimum level of the access log, and report whether it is set.
+func (c *Control) Level() (zapcore.Level, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.level.active(time.Now()) {
+		return c.level.value, true
+	}
+	return zapcore.DebugLevel, false
+}
+
+// SetEnableBody set enable request/response body until the ttl expires, ttl must be positive.
+func (c *Control) SetEnableBody(b bool, ttl time.Duration) error {
+	if ttl <= 0 {
+		return errControlTTL
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enableBody = &expirable[bool]{b, time.Now().Add(ttl)}
+	return nil
+}
+
+// EnableBody returns enable request/response body, and report whether it is set.
+func (c *Control) EnableBody() (enable, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.enableBody.active(time.Now()) {
+		return c.enableBody.value, true
+	}
+	return false, false
+}
+
+// SetDebugCurl enable the debug curl of the route until the ttl expires, or disable it,
+// method empty matches any method, ttl must be positive to enable.
+func (c *Control) SetDebugCurl(method, route string, enable bool, ttl time.Duration) error {
+	if enable && ttl <= 0 {
+		return errControlTTL
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if enable {
+		c.debugCurls[method+" "+route] = time.Now().Add(ttl)
+	} else {
+		delete(c.debugCurls, method+" "+route)
+	}
+	return nil
+}
+
+// DebugCurl report whether the debug curl of the route is enabled.
+func (c *Control) DebugCurl(method, route string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := time.Now()
+	for _, key := range [...]string{method + " " + route, " " + route} {
+		if at, ok := c.debugCurls[key]; ok && now.Before(at) {
+			return true
+		}
+	}
+	return false
+}
+
+// StartSession starts a debug session which expires after ttl,
+// it returns the session with the id.
+func (c *Control) StartSession(header, value, subject string, ttl time.Duration) (*DebugSession, error) {
+	if header == "" && subject == "" {
+		return nil, errors.New("gzap: debug session requires header or subject")
+	}
+	if ttl <= 0 {
+		return nil, errors.New("gzap: debug session requires a positive ttl")
+	}
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	s := &DebugSession{
+		Id:       hex.EncodeToString(id),
+		Header:   header,
+		Value:    value,
+		Subject:  subject,
+		ExpireAt: time.Now().Add(ttl),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prune(time.Now())
+	c.sessions = append(c.sessions, s)
+	return s, nil
+}
+
+// StopSession stops the debug session.
+func (c *Control) StopSession(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(c.sessions)
+	c.sessions = slices.DeleteFunc(c.sessions, func(s *DebugSession) bool { return s.Id == id })
+	c.prune(time.Now())
+	return len(c.sessions) != n
+}
+
+// Sessions returns the active debug sessions.
+func (c *Control) Sessions() []DebugSession {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := time.Now()
+	sessions := make([]DebugSession, 0, len(c.sessions))
+	for _, s := range c.sessions {
+		if now.Before(s.ExpireAt) {
+			sessions = append(sessions, *s)
+		}
+	}
+	return sessions
+}
+
+// prune removes the expired sessions and debug curls, must be called with the lock held.
+func (c *Control) prune(now time.Time) {
+	c.sessions = slices.DeleteFunc(c.sessions, func(s *DebugSession) bool { return !now.Before(s.ExpireAt) })
+	for key, at := range c.debugCurls {
+		if !now.Before(at) {
+			delete(c.debugCurls, key)
+		}
+	}
+}
+
+// mayCapture report whether the request may match a debug session at the beginning,
+// as the subject is not authenticated yet, any active subject session captures.
+func (c *Control) mayCapture(req *restful.Request) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := time.Now()
+	for _, s := range c.sessions {
+		if now.Before(s.ExpireAt) && (s.Subject != "" || s.matchHeader(req)) {
+			return true
+		}
+	}
+	return false
+}
+
+// match report whether the request matches a debug session.
+func (c *Control) match(req *restful.Request, subject string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := time.Now()
+	for _, s := range c.sessions {
+		if now.Before(s.ExpireAt) &&
+			(s.matchHeader(req) || (s.Subject != "" && s.Subject == subject)) {
+			return true
+		}
+	}
+	return false
+}
+
+// controlState the state of the control.
+type controlState struct {
+	Level      string         `json:"level,omitempty"`
+	EnableBody *bool          `json:"enableBody,omitempty"`
+	DebugCurls []string       `json:"debugCurls"`
+	Sessions   []DebugSession `json:"sessions"`
+}
+
+type controlRequest struct {
+	Level   string `json:"level"`
+	Enable  bool   `json:"enable"`
+	Method  string `json:"method"`
+	Route   string `json:"route"`
+	Header  string `json:"header"`
+	Value   string `json:"value"`
+	Subject string `json:"subject"`
+	// TTL the duration like "10m", it is required except to reset the level or disable the debug curl.
+	TTL string `json:"ttl"`
+}
+
+func (r *controlRequest) ttl() (time.Duration, error) {
+	if r.TTL == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(r.TTL)
+}
+
+// WebService returns the admin web service at the root path, like "/debug/gzap".
+//   - GET    {root}                 the current state.
+//   - PUT    {root}/level           {"level":"debug","ttl":"10m"}, empty level reset.
+//   - PUT    {root}/body            {"enable":true,"ttl":"10m"}
+//   - PUT    {root}/curl            {"method":"GET","route":"/users/{id}","enable":true,"ttl":"10m"}
+//   - POST   {root}/sessions        {"header":"X-Debug","value":"1","subject":"alice","ttl":"10m"}
+//   - DELETE {root}/sessions/{id}
+//
+// NOTE: The web service should be protected by the authentication filters.
+func (c *Control) WebService(root string) *restful.WebService {
+	ws := new(restful.WebService)
+	ws.Path(root).
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+	ws.Route(ws.GET("").To(c.getState).Doc("get the logging control state"))
+	ws.Route(ws.PUT("/level").To(c.putLevel).Doc("set the access log level, it can not be lower than the logger level"))
+	ws.Route(ws.PUT("/body").To(c.putBody).Doc("set enable request/response body"))
+	ws.Route(ws.PUT("/curl").To(c.putCurl).Doc("set enable debug curl of the route"))
+	ws.Route(ws.POST("/sessions").To(c.postSession).Doc("start a debug session"))
+	ws.Route(ws.DELETE("/sessions/{id}").To(c.deleteSession).Doc("stop a debug session"))
+	return ws
+}
+
+func (c *Control) state() *controlState {
+	st := &controlState{
+		DebugCurls: []string{},
+		Sessions:   c.Sessions(),
+	}
+	if lv, ok := c.Level(); ok {
+		st.Level = lv.String()
+	}
+	if b, ok := c.EnableBody(); ok {
+		st.EnableBody = &b
+	}
+	c.mu.RLock()
+	now := time.Now()
+	for key, at := range c.debugCurls {
+		if now.Before(at) {
+			st.DebugCurls = append(st.DebugCurls, key)
+		}
+	}
+	c.mu.RUnlock()
+	slices.Sort(st.DebugCurls)
+	return st
+}
+
+func (c *Control) getState(req *restful.Request, resp *restful.Response) {
+	_ = resp.WriteEntity(c.state())
+}
+
+func (c *Control) readRequest(req *restful.Request, resp *restful.Response) (*controlRequest, time.Duration, bool) {
+	r := &controlRequest{}
+	if err := req.ReadEntity(r); err != nil {
+		_ = resp.WriteError(http.StatusBadRequest, err)
+		return nil, 0, false
+	}
+	ttl, err := r.ttl()
+	if err != nil {
+		_ = resp.WriteError(http.StatusBadRequest, err)
+		return nil, 0, false
+	}
+	return r, ttl, true
+}
+
+func (c *Control) putLevel(req *restful.Request, resp *restful.Response) {
+	r, ttl, ok := c.readRequest(req, resp)
+	if !ok {
+		return
+	}
+	if r.Level == "" {
+		c.ResetLevel()
+	} else {
+		lv, err := zapcore.ParseLevel(r.Level)
+		if err != nil {
+			_ = resp.WriteError(http.StatusBadRequest, err)
+			return
+		}
+		if err = c.SetLevel(lv, ttl); err != nil {
+			_ = resp.WriteError(http.StatusBadRequest, err)
+			return
+		}
+	}
+	_ = resp.WriteEntity(c.state())
+}
+
+func (c *Control) putBody(req *restful.Request, resp *restful.Response) {
+	r, ttl, ok := c.readRequest(req, resp)
+	if !ok {
+		return
+	}
+	if err := c.SetEnableBody(r.Enable, ttl); err != nil {
+		_ = resp.WriteError(http.StatusBadRequest, err)
+		return
+	}
+	_ = resp.WriteEntity(c.state())
+}
+
+func (c *Control) putCurl(req *restful.Request, resp *restful.Response) {
+	r, ttl, ok := c.readRequest(req, resp)
+	if !ok {
+		return
+	}
+	if r.Route == "" {
+		_ = resp.WriteError(http.StatusBadRequest, errors.New("gzap: route is required"))
+		return
+	}
+	if err := c.SetDebugCurl(r.Method, r.Route, r.Enable, ttl); err != nil {
+		_ = resp.WriteError(http.StatusBadRequest, err)
+		return
+	}
+	_ = resp.WriteEntity(c.state())
+}
+
+func (c *Control) postSession(req *restful.Request, resp *restful.Response) {
+	r, ttl, ok := c.readRequest(req, resp)
+	if !ok {
+		return
+	}
+	s, err := c.StartSession(r.Header, r.Value, r.Subject, ttl)
+	if err != nil {
+		_ = resp.WriteError(http.StatusBadRequest, err)
+		return
+	}
+	_ = resp.WriteHeaderAndEntity(http.StatusCreated, s)
+}
+
+func (c *Control) deleteSession(req *restful.Request, resp *restful.Response) {
+	if !c.StopSession(req.PathParameter("id")) {
+		resp.WriteHeader(http.StatusNotFound)
+		return
+	}
+	resp.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,204 @@
+package gzap
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"go.uber.org/zap"
+
+	"github.com/nova-clouds/restful-contrib/realip"
+)
+
+// Format the access log format.
+type Format int
+
+const (
+	// FormatDefault the default field names, like "status", "method", "path".
+	FormatDefault Format = iota
+	// FormatECS the Elastic Common Schema field names, like "http.response.status_code",
+	// the fields not defined by ECS are under the custom "gzap" namespace, like "gzap.ttfb".
+	FormatECS
+	// FormatOTel the OpenTelemetry semantic convention field names, like "http.response.status_code".
+	FormatOTel
+	// FormatCombined the Apache/NCSA combined log format as a single message, without fields,
+	// the quote, backslash and control characters are escaped as "\xHH".
+	FormatCombined
+)
+
+// bodyKeys the field names of the body.
+type bodyKeys struct {
+	content, truncated, size string
+	// decodedSize the key of the decoded length of the decompressed body.
+	decodedSize string
+	// summary the key of the binary body summary, which is an object.
+	summary string
+	// stringContent logs the json body as a string instead of an object.
+	stringContent bool
+}
+
+// schema the field names of the access log.
+type schema struct {
+	title, status, method, path, route, query, ip, userAgent string
+	latency                                                  func(time.Duration) zap.Field
+	requestBody, responseBody                                bodyKeys
+	// bytes read from the request body and written to the response body.
+	bytesIn, bytesOut string
+	// time to first byte, the handler duration and the filter durations.
+	ttfb, handler, filters string
+	duration               func(key string, d time.Duration) zap.Field
+}
+
+var schemas = map[Format]*schema{
+	FormatDefault: {
+		title:     "title",
+		status:    "status",
+		method:    "method",
+		path:      "path",
+		route:     "route",
+		query:     "query",
+		ip:        "ip",
+		userAgent: "user-agent",
+		latency:   func(d time.Duration) zap.Field { return zap.Duration("latency", d) },
+		requestBody: bodyKeys{
+			content: "requestBody", truncated: "requestBodyTruncated", size: "requestBodySize",
+			decodedSize: "requestBodyDecodedSize", summary: "requestBody",
+		},
+		responseBody: bodyKeys{
+			content: "responseBody", truncated: "responseBodyTruncated", size: "responseBodySize",
+			decodedSize: "responseBodyDecodedSize", summary: "responseBody",
+		},
+		bytesIn:  "bytesIn",
+		bytesOut: "bytesOut",
+		ttfb:     "ttfb",
+		handler:  "handlerLatency",
+		filters:  "filters",
+		duration: zap.Duration,
+	},
+	FormatECS: {
+		title:     "labels.title",
+		status:    "http.response.status_code",
+		method:    "http.request.method",
+		path:      "url.path",
+		route:     "labels.route",
+		query:     "url.query",
+		ip:        "client.ip",
+		userAgent: "user_agent.original",
+		latency:   func(d time.Duration) zap.Field { return zap.Int64("event.duration", d.Nanoseconds()) },
+		requestBody: bodyKeys{
+			content: "http.request.body.content", truncated: "gzap.request.body.truncated", size: "http.request.body.bytes",
+			decodedSize: "gzap.request.body.decoded_bytes", summary: "gzap.request.body.summary", stringContent: true,
+		},
+		responseBody: bodyKeys{
+			content: "http.response.body.content", truncated: "gzap.response.body.truncated", size: "http.response.body.bytes",
+			decodedSize: "gzap.response.body.decoded_bytes", summary: "gzap.response.body.summary", stringContent: true,
+		},
+		bytesIn:  "http.request.bytes",
+		bytesOut: "http.response.bytes",
+		ttfb:     "gzap.ttfb",
+		handler:  "gzap.handler.duration",
+		filters:  "gzap.filters",
+		duration: func(key string, d time.Duration) zap.Field { return zap.Int64(key, d.Nanoseconds()) },
+	},
+	FormatOTel: {
+		title:     "http.route.title",
+		status:    "http.response.status_code",
+		method:    "http.request.method",
+		path:      "url.path",
+		route:     "http.route",
+		query:     "url.query",
+		ip:        "client.address",
+		userAgent: "user_agent.original",
+		latency:   func(d time.Duration) zap.Field { return zap.Float64("http.server.request.duration", d.Seconds()) },
+		requestBody: bodyKeys{
+			content: "http.request.body", truncated: "http.request.body.truncated", size: "http.request.body.size",
+			decodedSize: "http.request.body.decoded_size", summary: "http.request.body",
+		},
+		responseBody: bodyKeys{
+			content: "http.response.body", truncated: "http.response.body.truncated", size: "http.response.body.size",
+			decodedSize: "http.response.body.decoded_size", summary: "http.response.body",
+		},
+		bytesIn:  "http.request.size",
+		bytesOut: "http.response.size",
+		ttfb:     "http.server.time_to_first_byte",
+		handler:  "http.server.handler.duration",
+		filters:  "http.server.filters",
+		duration: func(key string, d time.Duration) zap.Field { return zap.Float64(key, d.Seconds()) },
+	},
+}
+
+// combinedLine returns the Apache/NCSA combined log format line:
+// %h - %u [%t] "%r" %>s %b "%{Referer}i" "%{User-agent}i"
+func combinedLine(req *restful.Request, resp *restful.Response, user string, start time.Time, uri string, bytesOut int64) string {
+	b := strings.Builder{}
+	b.Grow(256)
+	b.WriteString(dashIfEmpty(realip.ClientIP(req.Request)))
+	b.WriteString(" - ")
+	b.WriteString(dashIfEmpty(escapeLog(user)))
+	b.WriteString(" [")
+	b.WriteString(start.Format("02/Jan/2006:15:04:05 -0700"))
+	b.WriteString(`] "`)
+	b.WriteString(req.Request.Method)
+	b.WriteString(" ")
+	b.WriteString(escapeLog(uri))
+	b.WriteString(" ")
+	b.WriteString(req.Request.Proto)
+	b.WriteString(`" `)
+	b.WriteString(strconv.Itoa(resp.StatusCode()))
+	b.WriteString(" ")
+	if bytesOut > 0 {
+		b.WriteString(strconv.FormatInt(bytesOut, 10))
+	} else {
+		b.WriteString("-")
+	}
+	b.WriteString(` "`)
+	b.WriteString(dashIfEmpty(escapeLog(req.Request.Referer())))
+	b.WriteString(`" "`)
+	b.WriteString(dashIfEmpty(escapeLog(req.Request.UserAgent())))
+	b.WriteString(`"`)
+	return b.String()
+}
+
+// requestURI returns the request uri of the path and the raw query.
+func requestURI(path, query string) string {
+	if query == "" {
+		return path
+	}
+	return path + "?" + query
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// escapeLog escapes '"', '\' and the control characters as "\xHH" like nginx,
+// so the decoded path or the headers can not forge the log line.
+func escapeLog(s string) string {
+	i := strings.IndexFunc(s, needEscape)
+	if i < 0 {
+		return s
+	}
+	const hex = "0123456789ABCDEF"
+	b := strings.Builder{}
+	b.Grow(len(s) + 8)
+	b.WriteString(s[:i])
+	for ; i < len(s); i++ {
+		c := s[i]
+		if needEscape(rune(c)) {
+			b.WriteString(`\x`)
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0xf])
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func needEscape(r rune) bool {
+	return r == '"' || r == '\\' || r < 0x20 || r == 0x7f
+}
@@ -5,10 +5,8 @@ import (
 	"bytes"
 	"io"
 	"mime"
-	"net"
 	"net/http"
 	"net/http/httputil"
-	"os"
 	"runtime/debug"
 	"strings"
 	"sync/atomic"
@@ -19,7 +17,10 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
+	"github.com/nova-clouds/restful-contrib/dbstats"
+	"github.com/nova-clouds/restful-contrib/interceptor"
 	"github.com/nova-clouds/restful-contrib/internal/pool"
+	"github.com/nova-clouds/restful-contrib/realip"
 )
 
 // Option logger/recover option
@@ -57,7 +58,8 @@ func WithExternalEnableBody(b *atomic.Bool) Option {
 	}
 }
 
-// WithBodyLimit optional custom request/response body limit.
+// WithBodyLimit optional custom the max bytes of the request/response body to capture,
+// the rest of the body is not captured, but counted as the real size.
 // default: <=0, mean not limit
 func WithBodyLimit(limit int) Option {
 	return func(c *Config) {
@@ -65,6 +67,28 @@ func WithBodyLimit(limit int) Option {
 	}
 }
 
+// WithFailureSnapshot optional always capture at most limit bytes of the request/response body,
+// but only log them and the debug curl when the request fails, that is the status >= 500,
+// recovered from panic (see Recovery) or above the slow threshold.
+// The body logging is not required, limit <=0 mean disable.
+func WithFailureSnapshot(limit int) Option {
+	return func(c *Config) {
+		c.snapshotLimit = limit
+	}
+}
+
+// WithDBStats optional enable the per-request SQL statistics, which are carried in the request context
+// and recorded by the SQL logger, like gormzap, so the handler must use the request context for the queries.
+// The fields "db_queries", "db_time" and "db_slowest" are logged, and the level is raised to warn
+// with the field "db_repeated" when the same SQL fingerprint repeats more than repeatThreshold times,
+// which is the sign of the N+1 queries, repeatThreshold <=0 mean no warning.
+func WithDBStats(repeatThreshold int) Option {
+	return func(c *Config) {
+		c.dbStats = true
+		c.dbRepeatThreshold = repeatThreshold
+	}
+}
+
 // WithSkipRequestBody optional custom skip request body logging option.
 func WithSkipRequestBody(f func(req *restful.Request, resp *restful.Response) bool) Option {
 	return func(c *Config) {
@@ -102,6 +126,111 @@ func WithEnableDebugCurl(b bool) Option {
 	}
 }
 
+// WithBodyKind optional custom the body kind rule of the content type.
+// default: ContentKind
+func WithBodyKind(f func(contentType string) BodyKind) Option {
+	return func(c *Config) {
+		if f != nil {
+			c.bodyKind = f
+		}
+	}
+}
+
+// WithDecompressBody optional decompress the gzip/deflate body before logging,
+// otherwise the compressed body is logged as a summary.
+// At most the capture limit bytes are inflated, or 1MB if the capture is not limited.
+// default: false
+func WithDecompressBody(b bool) Option {
+	return func(c *Config) {
+		c.decompressBody = b
+	}
+}
+
+// WithSampler optional custom per route sampling and rate limit of the logging,
+// the requests logged at zap.WarnLevel or above are always logged.
+// default: nil, logs all.
+func WithSampler(s *Sampler) Option {
+	return func(c *Config) {
+		c.sampler = s
+	}
+}
+
+// WithSlowThreshold optional custom the slow threshold of all routes,
+// the slow requests are logged at zap.WarnLevel at least with the "slow" field.
+// default: 0, mean disable
+func WithSlowThreshold(d time.Duration) Option {
+	return func(c *Config) {
+		c.slowThresholdDefault = d
+	}
+}
+
+// WithRouteSlowThreshold optional custom the slow threshold of the route,
+// method is the http method, empty matches any method,
+// route is the route template, which is SelectedRoute().Path(), like "/users/{id}".
+func WithRouteSlowThreshold(method, route string, d time.Duration) Option {
+	return func(c *Config) {
+		if c.routeSlowThresholds == nil {
+			c.routeSlowThresholds = make(map[string]time.Duration)
+		}
+		c.routeSlowThresholds[method+" "+route] = d
+	}
+}
+
+// WithSlowStack optional capture the stack when the request crosses the slow threshold,
+// the stack is logged with the "slowStack" field, and "slowStackTruncated" if it is cut.
+// The capture stops the world, so it is limited to one per route per interval, see WithSlowStackInterval,
+// SlowStackAll is limited to 1MB, SlowStackHandler scans at most 64MB for the handler goroutine.
+// default: SlowStackNone
+func WithSlowStack(mode SlowStack) Option {
+	return func(c *Config) {
+		c.slowStack = mode
+	}
+}
+
+// WithSlowStackInterval optional custom the min interval of the stack captures per route,
+// <=0 mean capture every slow request.
+// default: 1 minute
+func WithSlowStackInterval(d time.Duration) Option {
+	return func(c *Config) {
+		c.slowStackInterval = d
+	}
+}
+
+// WithFormat optional custom the access log format.
+// default: FormatDefault
+func WithFormat(f Format) Option {
+	return func(c *Config) {
+		c.format = f
+	}
+}
+
+// WithRouteAsPath optional log the route template, like "/users/{id}", instead of the concrete path,
+// which avoids the PII and the high cardinality of the path, the concrete path is kept if no route is selected.
+func WithRouteAsPath(b bool) Option {
+	return func(c *Config) {
+		c.routeAsPath = b
+	}
+}
+
+// WithControl optional custom the runtime control, which controls the log level,
+// the body capture, the debug curl per route and the debug sessions at runtime,
+// it overrides the options while the change is active. see Control.WebService.
+// The control level only silences the access log, the entries are still filtered by the logger core level.
+func WithControl(ctl *Control) Option {
+	return func(c *Config) {
+		c.control = ctl
+	}
+}
+
+// WithRedactor optional custom redactor, which redacts the sensitive headers and
+// fields of the request/response body, the debug curl and the request dump of Recovery.
+// default: nil, not redact. see DefaultRedactor.
+func WithRedactor(r *Redactor) Option {
+	return func(c *Config) {
+		c.redactor = r
+	}
+}
+
 // Config logger/recover config
 type Config struct {
 	customFields []func(req *restful.Request, resp *restful.Response) zap.Field
@@ -118,8 +247,33 @@ type Config struct {
 	//  zap.InfoLevel: otherwise.
 	useLoggerLevel func(req *restful.Request, resp *restful.Response) zapcore.Level
 	enableBody     *atomic.Bool       // enable request/response body
-	limit          int                // <=0: mean not limit
+	limit          int                // max capture bytes, <=0: mean not limit
 	debugCurl      *httpcurl.HttpCurl // debug curl
+	redactor       *Redactor          // redact sensitive fields
+	// body kind of the content type, default: ContentKind
+	bodyKind       func(contentType string) BodyKind
+	decompressBody bool     // decompress gzip/deflate body before logging
+	sampler        *Sampler // per route sampling and rate limit
+	// slow threshold, <=0: mean disable
+	slowThresholdDefault time.Duration
+	routeSlowThresholds  map[string]time.Duration // per route slow threshold
+	slowStack            SlowStack                // capture the stack when crossing the slow threshold
+	slowStackInterval    time.Duration            // min interval of the stack captures per route
+	format               Format                   // access log format
+	// recovery responder, default: 500 Internal Server Error problem details.
+	recoveryResponder func(req *restful.Request, resp *restful.Response, err any)
+	panicReporters    []PanicReporter // panic reporters
+	panicStats        *PanicStats     // panic metrics
+	contextLogger     bool            // put the request-scoped logger into the context
+	control           *Control        // runtime control
+	// the subject of the request-scoped logger, default: subject.FromSubject
+	contextSubject func(req *restful.Request) string
+	filterTiming   bool // record the duration of each filter
+	routeAsPath    bool // log the route template instead of the concrete path
+	snapshotLimit  int  // max capture bytes of the failure snapshot, <=0: mean disable
+	dbStats        bool // per-request SQL statistics
+	// the repeat threshold of the same SQL fingerprint to warn, <=0: mean no warning
+	dbRepeatThreshold int
 }
 
 func skipRequestBody(req *restful.Request, resp *restful.Response) bool {
@@ -133,8 +287,8 @@ func skipRequestBody(req *restful.Request, resp *restful.Response) bool {
 }
 
 func skipResponseBody(req *restful.Request, resp *restful.Response) bool {
-	// TODO: add skip response body rule
-	return false
+	d, _, err := mime.ParseMediaType(resp.Header().Get("Content-Type"))
+	return err == nil && strings.HasPrefix(d, "multipart/")
 }
 
 func useLoggerLevel(req *restful.Request, resp *restful.Response) zapcore.Level {
@@ -153,13 +307,17 @@ func useLoggerLevel(req *restful.Request, resp *restful.Response) zapcore.Level
 
 func newConfig() Config {
 	return Config{
-		customFields:     nil,
-		skipLogging:      func(req *restful.Request, resp *restful.Response) bool { return false },
-		skipRequestBody:  func(req *restful.Request, resp *restful.Response) bool { return false },
-		skipResponseBody: func(req *restful.Request, resp *restful.Response) bool { return false },
-		useLoggerLevel:   useLoggerLevel,
-		enableBody:       &atomic.Bool{},
-		limit:            0,
+		customFields:      nil,
+		skipLogging:       func(req *restful.Request, resp *restful.Response) bool { return false },
+		skipRequestBody:   func(req *restful.Request, resp *restful.Response) bool { return false },
+		skipResponseBody:  func(req *restful.Request, resp *restful.Response) bool { return false },
+		useLoggerLevel:    useLoggerLevel,
+		enableBody:        &atomic.Bool{},
+		limit:             0,
+		bodyKind:          ContentKind,
+		slowStackInterval: time.Minute,
+		recoveryResponder: recoveryResponder,
+		contextSubject:    contextSubject,
 	}
 }
 
@@ -172,39 +330,93 @@ func Logger(logger *zap.Logger, opts ...Option) restful.FilterFunction {
 	for _, opt := range opts {
 		opt(&cfg)
 	}
+	slowLimit := &slowLimiter{interval: cfg.slowStackInterval}
 	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
-		respBodyBuilder := &strings.Builder{}
-		reqBody := "skip request body"
-		debugCurl := ""
-		hasSkipRequestBody := skipRequestBody(req, resp) || cfg.skipRequestBody(req, resp)
+		var reqBody, respBody *limitBuffer
+		var curlReq *http.Request
 
-		if cfg.enableBody.Load() {
-			resp.ResponseWriter = &bodyWriter{ResponseWriter: resp.ResponseWriter, dupBody: respBodyBuilder}
-			if !hasSkipRequestBody {
-				reqBodyBuf, err := io.ReadAll(req.Request.Body)
-				if err != nil {
-					_ = resp.WriteError(http.StatusInternalServerError, err)
-					return
-				}
-				req.Request.Body.Close() // nolint: errcheck
-				req.Request.Body = io.NopCloser(bytes.NewBuffer(reqBodyBuf))
-				if cfg.limit > 0 && len(reqBodyBuf) >= cfg.limit {
-					reqBody = "larger request body"
-				} else {
-					reqBody = string(reqBodyBuf)
-				}
+		route := ""
+		if r := req.SelectedRoute(); r != nil {
+			route = r.Path()
+		}
+		enableBody := cfg.enableBody.Load()
+		enableCurl := cfg.debugCurl != nil
+		mayDebug := false
+		if cfg.control != nil {
+			if b, ok := cfg.control.EnableBody(); ok {
+				enableBody = b
 			}
+			enableCurl = enableCurl || cfg.control.DebugCurl(req.Request.Method, route)
+			mayDebug = cfg.control.mayCapture(req)
 		}
-		if !hasSkipRequestBody && cfg.debugCurl != nil {
-			debugCurl, _ = cfg.debugCurl.IntoCurl(req.Request)
+		hasSkipRequestBody := skipRequestBody(req, resp) || cfg.skipRequestBody(req, resp)
+		// the failure snapshot captures the body, but logs only when the request fails.
+		snapshot := cfg.snapshotLimit > 0
+		limit := cfg.limit
+		if !enableBody && !enableCurl && !mayDebug {
+			// the snapshot limit only applies when nothing else logs the captured body.
+			limit = cfg.snapshotLimit
+		}
+		if enableBody || mayDebug || snapshot {
+			respBody = newLimitBuffer(limit)
+		}
+		var recorder *interceptor.Recorder
+		if respBody != nil {
+			resp.ResponseWriter, recorder = interceptor.Wrap(resp.ResponseWriter, respBody)
+		} else {
+			resp.ResponseWriter, recorder = interceptor.Wrap(resp.ResponseWriter, nil)
+		}
+		if !hasSkipRequestBody && (enableBody || enableCurl || mayDebug || snapshot) {
+			// capture the request body lazily when it is read.
+			reqBody = newLimitBuffer(limit)
+			if req.Request.Body != nil && req.Request.Body != http.NoBody {
+				req.Request.Body = &teeReadCloser{rc: req.Request.Body, w: reqBody}
+			}
+			if enableCurl || mayDebug || snapshot {
+				curlReq = req.Request.Clone(req.Request.Context())
+			}
+		}
+		// count the request body bytes read on the wire.
+		bytesIn := &countReadCloser{}
+		if req.Request.Body != nil && req.Request.Body != http.NoBody {
+			bytesIn.rc = req.Request.Body
+			req.Request.Body = bytesIn
 		}
 
 		start := time.Now()
 		// some evil middlewares modify this values
 		path := req.Request.URL.Path
 		query := req.Request.URL.RawQuery
+		if cfg.routeAsPath && route != "" {
+			path = route
+		}
+		if cfg.redactor != nil {
+			query = cfg.redactor.Query(query)
+		}
+		var slowWatch *slowWatcher
+
+		if cfg.contextLogger {
+			NewContext(req, logger, cfg.contextSubject)
+		}
+
+		var stats *dbstats.Stats
+		if cfg.dbStats {
+			stats = dbstats.New()
+			req.Request = req.Request.WithContext(dbstats.NewContext(req.Request.Context(), stats))
+		}
+		next, timing := timedChain(chain, cfg.filterTiming)
+		threshold := cfg.slowThreshold(req.Request.Method, route)
+		if threshold > 0 && cfg.slowStack != SlowStackNone {
+			slowWatch = watchSlow(threshold, cfg.slowStack, slowLimit, req.Request.Method+" "+route)
+		}
 
 		defer func() {
+			latency := time.Since(start)
+			var slowStack []byte
+			var slowStackTruncated bool
+			if slowWatch != nil {
+				slowStack, slowStackTruncated = slowWatch.Stop()
+			}
 			if cfg.skipLogging(req, resp) {
 				return
 			}
@@ -215,42 +427,106 @@ func Logger(logger *zap.Logger, opts ...Option) restful.FilterFunction {
 			} else {
 				level = cfg.useLoggerLevel(req, resp)
 			}
+			slow := threshold > 0 && latency >= threshold
+			if slow && level < zapcore.WarnLevel {
+				level = zapcore.WarnLevel
+			}
+			var repeated []dbstats.Repeat
+			if stats != nil && cfg.dbRepeatThreshold > 0 {
+				repeated = stats.Repeated(cfg.dbRepeatThreshold)
+				if len(repeated) > 0 && level < zapcore.WarnLevel {
+					level = zapcore.WarnLevel
+				}
+			}
+			// the request matches a debug session captures everything.
+			debugging := mayDebug && cfg.control.match(req, cfg.contextSubject(req))
+			if !debugging {
+				if cfg.control != nil {
+					if lv, ok := cfg.control.Level(); ok && level < lv {
+						return
+					}
+				}
+				if cfg.sampler != nil &&
+					level < zapcore.WarnLevel &&
+					!cfg.sampler.Allow(req.Request.Method, route) {
+					return
+				}
+			}
+			if cfg.format == FormatCombined {
+				logger.Log(level, combinedLine(req, resp, cfg.contextSubject(req), start, requestURI(path, query), recorder.Written()))
+				return
+			}
 
-			fc := pool.Get()
-			defer pool.Put(fc)
-			route := ""
+			sc := cfg.schema()
 			title := zap.Skip()
 			if r := req.SelectedRoute(); r != nil {
-				route = r.Path()
-				title = zap.String("title", r.Doc())
+				title = zap.String(sc.title, r.Doc())
 			}
+			fc := pool.Get()
+			defer pool.Put(fc)
 			fc.Fields = append(fc.Fields,
 				title,
-				zap.Int("status", resp.StatusCode()),
-				zap.String("method", req.Request.Method),
-				zap.String("path", path),
-				zap.String("route", route),
-				zap.String("query", query),
-				zap.String("ip", req.Request.RemoteAddr),
-				zap.String("user-agent", req.Request.UserAgent()),
-				zap.Duration("latency", time.Since(start)),
+				zap.Int(sc.status, resp.StatusCode()),
+				zap.String(sc.method, req.Request.Method),
+				zap.String(sc.path, path),
+				zap.String(sc.route, route),
+				zap.String(sc.query, query),
+				zap.String(sc.ip, realip.ClientIP(req.Request)),
+				zap.String(sc.userAgent, req.Request.UserAgent()),
+				sc.latency(latency),
+				zap.Int64(sc.bytesIn, bytesIn.n),
+				zap.Int64(sc.bytesOut, recorder.Written()),
 			)
-			if cfg.enableBody.Load() {
-				respBody := "skip response body"
-				if hasSkipResponseBody := skipResponseBody(req, resp) || cfg.skipResponseBody(req, resp); !hasSkipResponseBody {
-					if cfg.limit > 0 && respBodyBuilder.Len() >= cfg.limit {
-						respBody = "larger response body"
-					} else {
-						respBody = respBodyBuilder.String()
-					}
+			if ttfb := recorder.TimeToFirstByte(); ttfb > 0 {
+				fc.Fields = append(fc.Fields, sc.duration(sc.ttfb, ttfb))
+			}
+			if timing.handler >= 0 {
+				fc.Fields = append(fc.Fields, sc.duration(sc.handler, timing.handler))
+			}
+			if len(timing.filters) > 0 {
+				fc.Fields = append(fc.Fields, zap.Array(sc.filters, timing))
+			}
+			if slow {
+				fc.Fields = append(fc.Fields,
+					zap.Bool("slow", true),
+					zap.Duration("slowThreshold", threshold),
+				)
+				if len(slowStack) > 0 {
+					fc.Fields = append(fc.Fields, zap.ByteString("slowStack", slowStack))
+				}
+				if slowStackTruncated {
+					fc.Fields = append(fc.Fields, zap.Bool("slowStackTruncated", true))
 				}
+			}
+			if stats != nil {
+				slowest, _ := stats.Slowest()
 				fc.Fields = append(fc.Fields,
-					zap.String("requestBody", reqBody),
-					zap.String("responseBody", respBody),
+					zap.Int("db_queries", stats.Queries()),
+					zap.Duration("db_time", stats.Total()),
+					zap.Duration("db_slowest", slowest),
 				)
+				if len(repeated) > 0 {
+					fc.Fields = append(fc.Fields, zap.Array("db_repeated", repeats(repeated)))
+				}
 			}
-			if debugCurl != "" {
-				fc.Fields = append(fc.Fields, zap.String("curl", debugCurl))
+			failed := snapshot &&
+				(resp.StatusCode() >= http.StatusInternalServerError || Panicked(req) || slow)
+			if enableBody || debugging || failed {
+				if reqBody != nil {
+					fc.Fields = cfg.appendBody(fc.Fields, sc.requestBody, req.Request.Header, reqBody)
+				} else {
+					fc.Fields = append(fc.Fields, zap.String(sc.requestBody.content, "skip request body"))
+				}
+				if hasSkipResponseBody := skipResponseBody(req, resp) || cfg.skipResponseBody(req, resp); !hasSkipResponseBody {
+					fc.Fields = cfg.appendBody(fc.Fields, sc.responseBody, resp.Header(), respBody)
+				} else {
+					fc.Fields = append(fc.Fields, zap.String(sc.responseBody.content, "skip response body"))
+				}
+			}
+			if curlReq != nil && (enableCurl || debugging || failed) {
+				if debugCurl, err := cfg.intoCurl(curlReq, reqBody.Bytes()); err == nil {
+					fc.Fields = append(fc.Fields, zap.String("curl", debugCurl))
+				}
 			}
 			for _, fieldFunc := range cfg.customFields {
 				fc.Fields = append(fc.Fields, fieldFunc(req, resp))
@@ -260,8 +536,59 @@ func Logger(logger *zap.Logger, opts ...Option) restful.FilterFunction {
 			}
 			logger.Log(level, "logging", fc.Fields...)
 		}()
-		chain.ProcessFilter(req, resp)
+		next.ProcessFilter(req, resp)
+	}
+}
+
+// repeats the repeated SQL fingerprints.
+type repeats []dbstats.Repeat
+
+// MarshalLogArray implements zapcore.ArrayMarshaler.
+func (rs repeats) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, r := range rs {
+		err := enc.AppendObject(zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc.AddString("fingerprint", r.Fingerprint)
+			enc.AddInt("count", r.Count)
+			return nil
+		}))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Config) schema() *schema {
+	if sc, ok := schemas[c.format]; ok {
+		return sc
+	}
+	return schemas[FormatDefault]
+}
+
+func (c *Config) redactBody(contentType string, body []byte) string {
+	if c.redactor == nil {
+		return string(body)
+	}
+	return c.redactor.Body(contentType, body)
+}
+
+// intoCurl returns the debug curl of the request with the captured body,
+// the sensitive headers and fields are redacted.
+func (c *Config) intoCurl(r *http.Request, body []byte) (string, error) {
+	r.Body = http.NoBody
+	if c.redactor != nil {
+		r.Header = c.redactor.Header(r.Header)
+		r.URL.RawQuery = c.redactor.Query(r.URL.RawQuery)
+		if len(body) > 0 {
+			r.Body = io.NopCloser(strings.NewReader(c.redactor.Body(r.Header.Get("Content-Type"), body)))
+		}
+	} else if len(body) > 0 {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if c.debugCurl == nil {
+		return httpcurl.IntoCurl(r)
 	}
+	return c.debugCurl.IntoCurl(r)
 }
 
 // Recovery returns a gin.HandlerFunc (middleware)
@@ -269,41 +596,44 @@ func Logger(logger *zap.Logger, opts ...Option) restful.FilterFunction {
 // All errors are logged using zap.Error().
 // stack means whether output the stack info.
 // The stack info is easy to find where the error occurs but the stack info is too large.
+// The response is written by the recovery responder, see WithRecoveryResponder, unless
+// the response has already started, the panic is reported to the panic reporters, see WithPanicReporter.
 func Recovery(logger *zap.Logger, stack bool, opts ...Option) restful.FilterFunction {
 	cfg := newConfig()
 	for _, opt := range opts {
 		opt(&cfg)
 	}
-	if stack {
-		cfg.customFields = append(cfg.customFields, func(req *restful.Request, resp *restful.Response) zap.Field {
-			return zap.ByteString("stack", debug.Stack())
-		})
-	}
 	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		// reuse the recorder of the Logger, if any, to know whether the response has started.
+		recorder, ok := interceptor.RecorderOf(resp.ResponseWriter)
+		if !ok {
+			resp.ResponseWriter, recorder = interceptor.Wrap(resp.ResponseWriter, nil)
+		}
 		defer func() {
 			if err := recover(); err != nil {
-				// Check for a broken connection, as it is not really a
-				// condition that warrants a panic stack trace.
-				var brokenPipe bool
-				if ne, ok := err.(*net.OpError); ok {
-					if se, ok := ne.Err.(*os.SyscallError); ok {
-						if strings.Contains(strings.ToLower(se.Error()), "broken pipe") ||
-							strings.Contains(strings.ToLower(se.Error()), "connection reset by peer") {
-							brokenPipe = true
-						}
+				var stackBuf []byte
+
+				req.SetAttribute(PanicAttribute, true)
+				brokenPipe := isBrokenPipe(err)
+				if cfg.panicStats != nil {
+					route := ""
+					if r := req.SelectedRoute(); r != nil {
+						route = r.Path()
 					}
+					cfg.panicStats.incr(req.Request.Method, route, brokenPipe)
 				}
-
-				httpRequest, _ := httputil.DumpRequest(req.Request, false)
+				httpRequest := cfg.dumpRequest(req.Request)
 				if brokenPipe {
+					// If the connection is dead, we can't write a status to it.
 					logger.Error(req.Request.URL.Path,
 						zap.Any("error", err),
 						zap.ByteString("request", httpRequest),
 					)
-					// If the connection is dead, we can't write a status to it.
-					resp.WriteError(http.StatusInternalServerError, err.(error)) // nolint: errcheck
 					return
 				}
+				if stack || len(cfg.panicReporters) > 0 {
+					stackBuf = debug.Stack()
+				}
 
 				fc := pool.Get()
 				defer pool.Put(fc)
@@ -311,30 +641,40 @@ func Recovery(logger *zap.Logger, stack bool, opts ...Option) restful.FilterFunc
 					zap.Any("error", err),
 					zap.ByteString("request", httpRequest),
 				)
+				if stack {
+					fc.Fields = append(fc.Fields, zap.ByteString("stack", stackBuf))
+				}
 				for _, field := range cfg.customFields {
 					fc.Fields = append(fc.Fields, field(req, resp))
 				}
+				if status := recorder.Status(); status != 0 {
+					fc.Fields = append(fc.Fields, zap.Int("responseStatus", status))
+				}
 				logger.Error("recovery from panic", fc.Fields...)
-				resp.WriteErrorString(http.StatusInternalServerError, "panic") // nolint: errcheck
+				for _, report := range cfg.panicReporters {
+					report(req, err, stackBuf)
+				}
+				// the status and the headers are already sent.
+				if recorder.Status() == 0 {
+					cfg.recoveryResponder(req, resp, err)
+				}
 			}
 		}()
 		chain.ProcessFilter(req, resp)
 	}
 }
 
-type bodyWriter struct {
-	http.ResponseWriter
-	dupBody *strings.Builder
-}
-
-func (w *bodyWriter) Write(b []byte) (int, error) {
-	w.dupBody.Write(b)
-	return w.ResponseWriter.Write(b)
-}
-
-func (w *bodyWriter) WriteString(s string) (int, error) {
-	w.dupBody.WriteString(s)
-	return io.WriteString(w.ResponseWriter, s)
+// dumpRequest dumps the request without the body, the sensitive headers and query parameters are redacted.
+func (c *Config) dumpRequest(r *http.Request) []byte {
+	if c.redactor != nil {
+		r = r.Clone(r.Context())
+		r.Header = c.redactor.Header(r.Header)
+		r.URL.RawQuery = c.redactor.Query(r.URL.RawQuery)
+		// DumpRequest prefers the raw RequestURI which carries the origin query.
+		r.RequestURI = ""
+	}
+	b, _ := httputil.DumpRequest(r, false)
+	return b
 }
 
 // Any custom immutable any field
@@ -0,0 +1,770 @@
+package gzap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/nova-clouds/restful-contrib/authj"
+	"github.com/nova-clouds/restful-contrib/gormzap"
+	"github.com/nova-clouds/restful-contrib/traceid"
+)
+
+func testGzapRequest(t *testing.T, router http.Handler, method, path, body string) {
+	t.Helper()
+	r, err := http.NewRequestWithContext(context.TODO(), method, path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+}
+
+func TestLoggerBodyLimit(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Filter(Logger(zap.New(core), WithEnableBody(true), WithBodyLimit(4), WithEnableDebugCurl(true)))
+	ws.Route(ws.POST("/echo").To(func(req *restful.Request, resp *restful.Response) {
+		b, _ := io.ReadAll(req.Request.Body)
+		_, _ = resp.Write(b)
+	}))
+	router.Add(ws)
+
+	testGzapRequest(t, router, http.MethodPost, "/echo", "0123456789")
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("supposed to be 1 log entry, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["requestBody"] != "0123" || fields["requestBodySize"] != int64(10) || fields["requestBodyTruncated"] != true {
+		t.Errorf("unexpected request body fields: %v", fields)
+	}
+	if fields["responseBody"] != "0123" || fields["responseBodySize"] != int64(10) {
+		t.Errorf("unexpected response body fields: %v", fields)
+	}
+	if curl, _ := fields["curl"].(string); !strings.Contains(curl, "-d '0123'") {
+		t.Errorf("unexpected curl: %s", curl)
+	}
+}
+
+func TestLoggerBodyLimitRedact(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Filter(Logger(zap.New(core),
+		WithEnableBody(true),
+		WithBodyLimit(40),
+		WithEnableDebugCurl(true),
+		WithRedactor(DefaultRedactor()),
+	))
+	ws.Route(ws.POST("/login").To(func(req *restful.Request, resp *restful.Response) {
+		_, _ = io.Copy(io.Discard, req.Request.Body)
+	}))
+	router.Add(ws)
+
+	for _, contentType := range []string{"application/json", ""} {
+		logs.TakeAll()
+		body := `{"username":"alice","password":"hunter2","remember":true}`
+		r, _ := http.NewRequestWithContext(context.TODO(), http.MethodPost, "/login", strings.NewReader(body))
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), r)
+
+		entries := logs.All()
+		if len(entries) != 1 {
+			t.Fatalf("supposed to be 1 log entry, got %d", len(entries))
+		}
+		fields := entries[0].ContextMap()
+		if fields["requestBody"] != "******" || fields["requestBodyTruncated"] != true {
+			t.Errorf("truncated body should be masked: %v", fields)
+		}
+		if curl, _ := fields["curl"].(string); strings.Contains(curl, "hunter") {
+			t.Errorf("truncated body should be masked in curl: %s", curl)
+		}
+	}
+}
+
+func TestLoggerBodyKind(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Filter(Logger(zap.New(core), WithEnableBody(true), WithDecompressBody(true)))
+	ws.Route(ws.POST("/json").To(func(req *restful.Request, resp *restful.Response) {
+		_, _ = io.Copy(io.Discard, req.Request.Body)
+		resp.Header().Set("Content-Type", "image/png")
+		_, _ = resp.Write([]byte{0x89, 'P', 'N', 'G'})
+	}))
+	ws.Route(ws.GET("/gzip").To(func(req *restful.Request, resp *restful.Response) {
+		resp.Header().Set("Content-Type", "text/plain")
+		resp.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(resp)
+		_, _ = zw.Write([]byte("hello"))
+		_ = zw.Close()
+	}))
+	router.Add(ws)
+
+	r, _ := http.NewRequestWithContext(context.TODO(), http.MethodPost, "/json", strings.NewReader(`{"id":1,"tags":["a"]}`))
+	r.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	testGzapRequest(t, router, http.MethodGet, "/gzip", "")
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("supposed to be 2 log entries, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if !reflect.DeepEqual(fields["requestBody"], map[string]any{"id": json.Number("1"), "tags": []any{"a"}}) {
+		t.Errorf("json body should be logged as object: %#v", fields["requestBody"])
+	}
+	if !reflect.DeepEqual(fields["responseBody"], map[string]any{"contentType": "image/png", "contentEncoding": "", "size": int64(4)}) {
+		t.Errorf("binary body should be logged as summary: %#v", fields["responseBody"])
+	}
+	if fields = entries[1].ContextMap(); fields["responseBody"] != "hello" {
+		t.Errorf("gzip body should be decompressed: %#v", fields["responseBody"])
+	}
+}
+
+func TestLoggerDecompressedBodySize(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	var wire bytes.Buffer
+	zw := gzip.NewWriter(&wire)
+	_, _ = zw.Write([]byte(strings.Repeat("0123456789", 10)))
+	_ = zw.Close()
+	// the body on the wire is larger than the limit.
+
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Filter(Logger(zap.New(core), WithEnableBody(true), WithDecompressBody(true), WithBodyLimit(32)))
+	ws.Route(ws.GET("/gzip").To(func(req *restful.Request, resp *restful.Response) {
+		resp.Header().Set("Content-Type", "text/plain")
+		resp.Header().Set("Content-Encoding", "gzip")
+		_, _ = resp.Write(wire.Bytes())
+	}))
+	router.Add(ws)
+
+	testGzapRequest(t, router, http.MethodGet, "/gzip", "")
+
+	fields := logs.All()[0].ContextMap()
+	body, _ := fields["responseBody"].(string)
+	if body == "" || !strings.HasPrefix(strings.Repeat("0123456789", 10), body) || fields["responseBodyTruncated"] != true {
+		t.Errorf("the decompressed body should be truncated: %v", fields)
+	}
+	if fields["responseBodySize"] != int64(wire.Len()) {
+		t.Errorf("responseBodySize = %v, want the size on the wire %d", fields["responseBodySize"], wire.Len())
+	}
+	if fields["responseBodyDecodedSize"] != int64(len(body)) {
+		t.Errorf("responseBodyDecodedSize = %v, want the decoded length %d", fields["responseBodyDecodedSize"], len(body))
+	}
+}
+
+func TestDecompressLimit(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write(bytes.Repeat([]byte("a"), 2*maxDecompressedBody))
+	_ = zw.Close()
+
+	var tests = []struct {
+		name  string
+		limit int
+		want  int
+	}{
+		{"capture limit", 16, 16},
+		{"not limit", 0, maxDecompressedBody},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, truncated, err := decompress("gzip", buf.Bytes(), false, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(body) != tt.want || !truncated {
+				t.Errorf("decompress() = %d bytes, truncated %v, want %d bytes truncated", len(body), truncated, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoggerSampler(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Filter(Logger(zap.New(core), WithSampler(NewSampler(
+		SampleRule{Method: http.MethodGet, Route: "/healthz", Every: 10},
+	))))
+	ws.Route(ws.GET("/healthz").To(func(req *restful.Request, resp *restful.Response) {
+		if req.QueryParameter("fail") != "" {
+			resp.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	router.Add(ws)
+
+	for i := 0; i < 20; i++ {
+		testGzapRequest(t, router, http.MethodGet, "/healthz", "")
+	}
+	testGzapRequest(t, router, http.MethodGet, "/healthz?fail=1", "")
+
+	if n := logs.FilterField(zap.String("route", "/healthz")).Len(); n != 3 {
+		t.Errorf("supposed to log 2 sampled and 1 error healthz requests, got %d", n)
+	}
+
+	st := &sampleState{SampleRule: SampleRule{Rate: 2}}
+	now := time.Unix(1000, 0)
+	if !st.allow(now) || !st.allow(now) || st.allow(now) || !st.allow(now.Add(time.Second)) {
+		t.Errorf("supposed to log 2 requests per second")
+	}
+}
+
+func TestLoggerSlow(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Filter(Logger(zap.New(core),
+		WithSlowThreshold(time.Hour),
+		WithRouteSlowThreshold(http.MethodGet, "/slow", 10*time.Millisecond),
+		WithSlowStack(SlowStackHandler),
+	))
+	ws.Route(ws.GET("/slow").To(func(req *restful.Request, resp *restful.Response) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	ws.Route(ws.GET("/fast").To(func(req *restful.Request, resp *restful.Response) {}))
+	router.Add(ws)
+
+	testGzapRequest(t, router, http.MethodGet, "/slow", "")
+	testGzapRequest(t, router, http.MethodGet, "/fast", "")
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("supposed to be 2 log entries, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if entries[0].Level != zap.WarnLevel || fields["slow"] != true {
+		t.Errorf("slow request should be logged at warn level with slow field: %v", fields)
+	}
+	if stack, _ := fields["slowStack"].(string); !strings.Contains(stack, "TestLoggerSlow") {
+		t.Errorf("slow stack should be the handler stack: %s", stack)
+	}
+	if fields = entries[1].ContextMap(); entries[1].Level != zap.InfoLevel || fields["slow"] != nil {
+		t.Errorf("fast request should not be slow: %v", fields)
+	}
+	// the stack is captured once per route per interval.
+	logs.TakeAll()
+	testGzapRequest(t, router, http.MethodGet, "/slow", "")
+	if fields = logs.All()[0].ContextMap(); fields["slow"] != true || fields["slowStack"] != nil {
+		t.Errorf("slow stack should be rate limited: %v", fields)
+	}
+}
+
+func blockSlowHandler(gid chan<- []byte, done <-chan struct{}) {
+	gid <- currentGoroutineId()
+	<-done
+}
+
+func TestHandlerStack(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	// the stack of all goroutines is larger than maxStackSize.
+	for i := 0; i < 10000; i++ {
+		go func() { <-done }()
+	}
+	gids := make(chan []byte)
+	go blockSlowHandler(gids, done)
+	gid := <-gids
+
+	if _, truncated := allStack(); !truncated {
+		t.Fatalf("the stack of all goroutines should be truncated")
+	}
+	stack, truncated := handlerStack(gid)
+	if truncated || !bytes.HasPrefix(stack, []byte("goroutine "+string(gid)+" ")) ||
+		!bytes.Contains(stack, []byte("blockSlowHandler")) || bytes.Contains(stack, []byte("\n\n")) {
+		t.Errorf("handlerStack() = %s, %v, supposed to be the handler stack only", stack, truncated)
+	}
+	if stack, truncated = handlerStack(nil); stack != nil || !truncated {
+		t.Errorf("unknown goroutine should be truncated")
+	}
+}
+
+func TestSlowLimiter(t *testing.T) {
+	l := &slowLimiter{interval: time.Minute}
+	now := time.Now()
+	if !l.allow("GET /a", now) || l.allow("GET /a", now.Add(time.Second)) {
+		t.Errorf("one capture per interval")
+	}
+	if !l.allow("GET /b", now) || !l.allow("GET /a", now.Add(time.Minute)) {
+		t.Errorf("other routes or the next interval should be allowed")
+	}
+	if l = (&slowLimiter{}); !l.allow("GET /a", now) || !l.allow("GET /a", now) {
+		t.Errorf("no interval should not limit")
+	}
+}
+
+func TestLoggerFormat(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Route(ws.POST("/ecs").Filter(Logger(zap.New(core), WithFormat(FormatECS), WithEnableBody(true))).To(func(req *restful.Request, resp *restful.Response) {
+		_, _ = io.ReadAll(req.Request.Body)
+		resp.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(resp, `{"b":2}`)
+	}))
+	ws.Route(ws.GET("/combined").Filter(Logger(zap.New(core), WithFormat(FormatCombined))).To(func(req *restful.Request, resp *restful.Response) {
+		_, _ = io.WriteString(resp, "hello")
+	}))
+	router.Add(ws)
+
+	r, _ := http.NewRequestWithContext(context.TODO(), http.MethodPost, "/ecs?a=1", strings.NewReader(`{"a":1}`))
+	r.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	r, _ = http.NewRequestWithContext(context.TODO(), http.MethodGet, "/combined?a=1", http.NoBody)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("User-Agent", "curl/8.0")
+	r.Header.Set("Referer", "http://a\"\n127.0.0.1")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("supposed to be 2 log entries, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["http.response.status_code"] != int64(200) || fields["url.query"] != "a=1" || fields["http.request.method"] != "POST" {
+		t.Errorf("unexpected ecs fields: %v", fields)
+	}
+	// the body content is a string in ECS, the custom fields are under the "gzap" namespace.
+	if fields["http.request.body.content"] != `{"a":1}` || fields["http.response.body.content"] != `{"b":2}` {
+		t.Errorf("ecs body content should be a string: %v", fields)
+	}
+	if _, ok := fields["gzap.ttfb"].(int64); !ok {
+		t.Errorf("ecs ttfb should be under the gzap namespace: %v", fields)
+	}
+	msg := entries[1].Message
+	if !strings.HasPrefix(msg, "10.0.0.1 - - [") || !strings.HasSuffix(msg, `] "GET /combined?a=1 HTTP/1.1" 200 5 "http://a\x22\x0A127.0.0.1" "curl/8.0"`) {
+		t.Errorf("unexpected combined line: %s", msg)
+	}
+}
+
+func TestEscapeLog(t *testing.T) {
+	var tests = []struct {
+		s    string
+		want string
+	}{
+		{"/users/1?a=b", "/users/1?a=b"},
+		{"/a\n127.0.0.1 - - [forged]", `/a\x0A127.0.0.1 - - [forged]`},
+		{`Mozilla "quoted" \ \x7f` + "\x7f\t", `Mozilla \x22quoted\x22 \x5C \x5Cx7f\x7F\x09`},
+		{"/用户", "/用户"},
+	}
+	for _, tt := range tests {
+		if got := escapeLog(tt.s); got != tt.want {
+			t.Errorf("escapeLog(%q) = %s, want %s", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	stats := NewPanicStats()
+	var reported any
+
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Filter(Recovery(zap.New(core), false,
+		WithRedactor(DefaultRedactor()),
+		WithPanicStats(stats),
+		WithPanicReporter(func(req *restful.Request, err any, stack []byte) {
+			reported = err
+			if !bytes.Contains(stack, []byte("TestRecovery")) {
+				t.Errorf("reporter should receive the panic stack")
+			}
+		}),
+	))
+	ws.Route(ws.GET("/panic").To(func(req *restful.Request, resp *restful.Response) {
+		panic("oops")
+	}))
+	ws.Route(ws.GET("/broken").To(func(req *restful.Request, resp *restful.Response) {
+		panic(&net.OpError{Op: "write", Err: os.NewSyscallError("write", syscall.EPIPE)})
+	}))
+	ws.Route(ws.GET("/started").To(func(req *restful.Request, resp *restful.Response) {
+		_, _ = resp.Write([]byte("partial"))
+		panic("oops")
+	}))
+	router.Add(ws)
+
+	r, _ := http.NewRequestWithContext(context.TODO(), http.MethodGet, "/panic?token=abc&page=1", http.NoBody)
+	r.RequestURI = r.URL.RequestURI()
+	r.Header.Set("Authorization", "Bearer abc")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("unexpected recovery response: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if reported != "oops" {
+		t.Errorf("panic should be reported, got %v", reported)
+	}
+	r, _ = http.NewRequestWithContext(context.TODO(), http.MethodGet, "/broken?token=abc", http.NoBody)
+	r.Header.Set("Cookie", "session=abc")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	if logs.FilterMessage("recovery from panic").Len() != 1 || logs.FilterMessage("/broken").Len() != 1 {
+		t.Errorf("unexpected recovery logs: %v", logs.All())
+	}
+	r, _ = http.NewRequestWithContext(context.TODO(), http.MethodGet, "/started?token=abc", http.NoBody)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "partial" {
+		t.Errorf("the started response should not be overwritten: %d %q", w.Code, w.Body.String())
+	}
+	if n := logs.FilterField(zap.Int("responseStatus", http.StatusOK)).Len(); n != 1 {
+		t.Errorf("the status of the started response should be logged, got %d", n)
+	}
+	for _, entry := range logs.All() {
+		dump, _ := entry.ContextMap()["request"].(string)
+		if strings.Contains(dump, "abc") || !strings.Contains(dump, "token=%2A%2A%2A%2A%2A%2A") {
+			t.Errorf("request dump should be redacted: %q", dump)
+		}
+	}
+	if stats.Total() != 3 || stats.BrokenPipes() != 1 || stats.Routes()["GET /panic"] != 1 {
+		t.Errorf("unexpected panic stats: %d %d %v", stats.Total(), stats.BrokenPipes(), stats.Routes())
+	}
+}
+
+func TestLoggerContextLogger(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Filter(Logger(zap.New(core), WithContextLogger(true)))
+	ws.Filter(traceid.TraceId())
+	ws.Filter(func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		authj.ContextWithSubject(req, resp, "alice")
+		chain.ProcessFilter(req, resp)
+	})
+	ws.Route(ws.GET("/users/{id}").To(func(req *restful.Request, resp *restful.Response) {
+		FromContext(req.Request.Context()).Info("handler")
+	}))
+	router.Add(ws)
+
+	r, _ := http.NewRequestWithContext(context.TODO(), http.MethodGet, "/users/1", http.NoBody)
+	r.Header.Set("X-Trace-Id", "abc")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	entries := logs.FilterMessage("handler").All()
+	if len(entries) != 1 {
+		t.Fatalf("supposed to be 1 handler log entry, got %d", len(entries))
+	}
+	want := map[string]any{"traceId": "abc", "route": "/users/{id}", "method": "GET", "subject": "alice"}
+	if fields := entries[0].ContextMap(); !reflect.DeepEqual(fields, want) {
+		t.Errorf("unexpected handler log fields: %v", fields)
+	}
+}
+
+func TestLoggerControl(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	ctl := NewControl()
+
+	router := restful.NewContainer()
+	router.Add(ctl.WebService("/debug/gzap"))
+	ws := new(restful.WebService)
+	ws.Filter(Logger(zap.New(core), WithControl(ctl)))
+	ws.Route(ws.POST("/echo").To(func(req *restful.Request, resp *restful.Response) {
+		b, _ := io.ReadAll(req.Request.Body)
+		_, _ = resp.Write(b)
+	}))
+	router.Add(ws)
+
+	admin := func(method, path, body string, code int) {
+		r, _ := http.NewRequestWithContext(context.TODO(), method, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != code {
+			t.Errorf("%s %s: %d, supposed to be %d, %s", method, path, w.Code, code, w.Body.String())
+		}
+	}
+	admin(http.MethodPut, "/debug/gzap/level", `{"level":"warn"}`, http.StatusBadRequest)
+	admin(http.MethodPut, "/debug/gzap/body", `{"enable":true,"ttl":"-1m"}`, http.StatusBadRequest)
+	admin(http.MethodPut, "/debug/gzap/curl", `{"route":"/echo","enable":true}`, http.StatusBadRequest)
+	admin(http.MethodPut, "/debug/gzap/curl", `{"route":"/echo"}`, http.StatusOK)
+	admin(http.MethodPut, "/debug/gzap/level", `{"level":"warn","ttl":"1m"}`, http.StatusOK)
+	admin(http.MethodPost, "/debug/gzap/sessions", `{"header":"X-Debug","value":"1","ttl":"1m"}`, http.StatusCreated)
+	admin(http.MethodPost, "/debug/gzap/sessions", `{"ttl":"1m"}`, http.StatusBadRequest)
+
+	testGzapRequest(t, router, http.MethodPost, "/echo", "hello")
+	if n := logs.FilterField(zap.String("route", "/echo")).Len(); n != 0 {
+		t.Errorf("info logs should be filtered by the control level, got %d", n)
+	}
+
+	r, _ := http.NewRequestWithContext(context.TODO(), http.MethodPost, "/echo", strings.NewReader("hello"))
+	r.Header.Set("X-Debug", "1")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	entries := logs.FilterField(zap.String("route", "/echo")).All()
+	if len(entries) != 1 {
+		t.Fatalf("debug session request should be logged, got %d", len(entries))
+	}
+	if fields := entries[0].ContextMap(); fields["requestBody"] != "hello" || fields["responseBody"] != "hello" || fields["curl"] == nil {
+		t.Errorf("debug session should capture everything: %v", fields)
+	}
+
+	if err := ctl.SetLevel(zap.DebugLevel, 0); err == nil {
+		t.Errorf("level without ttl should be rejected")
+	}
+	_ = ctl.SetLevel(zap.DebugLevel, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if _, ok := ctl.Level(); ok {
+		t.Errorf("level should expire")
+	}
+}
+
+func TestLoggerBodyStreaming(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Filter(Logger(zap.New(core), WithEnableBody(true)))
+	ws.Route(ws.GET("/events").To(func(req *restful.Request, resp *restful.Response) {
+		if _, ok := resp.ResponseWriter.(http.Flusher); !ok {
+			t.Errorf("response writer should implement http.Flusher")
+		}
+		_, _ = resp.Write([]byte("data: 1\n\n"))
+		resp.Flush()
+	}))
+	router.Add(ws)
+
+	r, _ := http.NewRequestWithContext(context.TODO(), http.MethodGet, "/events", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if !w.Flushed {
+		t.Errorf("response should be flushed")
+	}
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("supposed to be 1 log entry, got %d", len(entries))
+	}
+	if got := entries[0].ContextMap()["responseBody"]; got != "data: 1\n\n" {
+		t.Errorf("responseBody = %q", got)
+	}
+}
+
+func sleepFilter(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	time.Sleep(20 * time.Millisecond)
+	chain.ProcessFilter(req, resp)
+}
+
+func TestLoggerBytesAndTiming(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Filter(Logger(zap.New(core), WithFilterTiming(true)))
+	ws.Filter(sleepFilter)
+	ws.Route(ws.POST("/echo").To(func(req *restful.Request, resp *restful.Response) {
+		b, _ := io.ReadAll(req.Request.Body)
+		_, _ = resp.Write(b)
+		_, _ = resp.Write(b)
+	}))
+	router.Add(ws)
+
+	testGzapRequest(t, router, http.MethodPost, "/echo", "0123456789")
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("supposed to be 1 log entry, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["bytesIn"] != int64(10) || fields["bytesOut"] != int64(20) {
+		t.Errorf("bytesIn = %v, bytesOut = %v", fields["bytesIn"], fields["bytesOut"])
+	}
+	if ttfb, ok := fields["ttfb"].(time.Duration); !ok || ttfb < 20*time.Millisecond {
+		t.Errorf("ttfb = %v, should include the filter duration", fields["ttfb"])
+	}
+	if _, ok := fields["handlerLatency"].(time.Duration); !ok {
+		t.Errorf("handlerLatency should be present, got %v", fields["handlerLatency"])
+	}
+	filters, ok := fields["filters"].([]any)
+	if !ok || len(filters) != 1 {
+		t.Fatalf("filters = %v", fields["filters"])
+	}
+	filter := filters[0].(map[string]any)
+	if filter["name"] != "gzap.sleepFilter" {
+		t.Errorf("filter name = %v, want gzap.sleepFilter", filter["name"])
+	}
+	if d, ok := filter["duration"].(time.Duration); !ok || d < 20*time.Millisecond {
+		t.Errorf("filter duration = %v, want >= 20ms", filter["duration"])
+	}
+}
+
+func TestLoggerRouteAsPathAndQuery(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Filter(Logger(zap.New(core),
+		WithRouteAsPath(true),
+		WithRedactor(DefaultRedactor()),
+		WithEnableDebugCurl(true),
+	))
+	ws.Route(ws.GET("/users/{id}").To(func(req *restful.Request, resp *restful.Response) {}))
+	router.Add(ws)
+
+	testGzapRequest(t, router, http.MethodGet, "/users/alice@example.com?token=abc&page=2", "")
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("supposed to be 1 log entry, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["path"] != "/users/{id}" {
+		t.Errorf("path = %v, want the route template", fields["path"])
+	}
+	if fields["query"] != "token=%2A%2A%2A%2A%2A%2A&page=2" {
+		t.Errorf("query = %v, token should be masked", fields["query"])
+	}
+	if curl, _ := fields["curl"].(string); !strings.Contains(curl, "page=2") || strings.Contains(curl, "token=abc") {
+		t.Errorf("curl = %v, token should be masked", curl)
+	}
+}
+
+func TestLoggerFailureSnapshot(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Filter(Logger(zap.New(core), WithFailureSnapshot(4)))
+	ws.Filter(Recovery(zap.NewNop(), false))
+	handler := func(req *restful.Request, resp *restful.Response) {
+		b, _ := io.ReadAll(req.Request.Body)
+		switch req.PathParameter("status") {
+		case "500":
+			resp.WriteHeader(http.StatusInternalServerError)
+		case "panic":
+			panic("boom")
+		}
+		_, _ = resp.Write(b)
+	}
+	ws.Route(ws.POST("/{status}").To(handler))
+	router.Add(ws)
+
+	var tests = []struct {
+		path     string
+		snapshot bool
+	}{
+		{"/200", false},
+		{"/500", true},
+		{"/panic", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			logs.TakeAll()
+			testGzapRequest(t, router, http.MethodPost, tt.path, "0123456789")
+			entries := logs.TakeAll()
+			if len(entries) != 1 {
+				t.Fatalf("supposed to be 1 log entry, got %d", len(entries))
+			}
+			fields := entries[0].ContextMap()
+			_, hasBody := fields["requestBody"]
+			_, hasCurl := fields["curl"]
+			if hasBody != tt.snapshot || hasCurl != tt.snapshot {
+				t.Fatalf("requestBody = %v, curl = %v, want snapshot %v", fields["requestBody"], fields["curl"], tt.snapshot)
+			}
+			if tt.snapshot && (fields["requestBody"] != "0123" || fields["requestBodySize"] != int64(10)) {
+				t.Errorf("requestBody = %v, size = %v", fields["requestBody"], fields["requestBodySize"])
+			}
+		})
+	}
+}
+
+func TestLoggerFailureSnapshotWithCurl(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	ctl := NewControl()
+
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Filter(Logger(zap.New(core), WithFailureSnapshot(4), WithControl(ctl)))
+	ws.Route(ws.POST("/echo").To(func(req *restful.Request, resp *restful.Response) {
+		b, _ := io.ReadAll(req.Request.Body)
+		resp.WriteHeader(http.StatusInternalServerError)
+		_, _ = resp.Write(b)
+	}))
+	router.Add(ws)
+
+	if err := ctl.SetDebugCurl(http.MethodPost, "/echo", true, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	testGzapRequest(t, router, http.MethodPost, "/echo", "0123456789")
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("supposed to be 1 log entry, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if curl, _ := fields["curl"].(string); !strings.Contains(curl, "-d '0123456789'") {
+		t.Errorf("debug curl should not be cut by the snapshot limit: %s", curl)
+	}
+}
+
+func TestLoggerDBStats(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Filter(Logger(zap.New(core), WithDBStats(2)))
+	ws.Route(ws.GET("/orders").To(func(req *restful.Request, resp *restful.Response) {
+		sqlLogger := gormzap.New(zap.NewNop())
+		ctx := req.Request.Context()
+		begin := time.Now().Add(-5 * time.Millisecond)
+		sqlLogger.Trace(ctx, begin, func() (string, int64) { return "SELECT * FROM orders", 3 }, nil)
+		for i := 1; i <= 3; i++ {
+			sqlLogger.Trace(ctx, time.Now(), func() (string, int64) {
+				return "SELECT * FROM users WHERE id = " + strconv.Itoa(i), 1
+			}, nil)
+		}
+	}))
+	router.Add(ws)
+
+	testGzapRequest(t, router, http.MethodGet, "/orders", "")
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("supposed to be 1 log entry, got %d", len(entries))
+	}
+	if entries[0].Level != zap.WarnLevel {
+		t.Errorf("level = %v, want warn for the N+1 queries", entries[0].Level)
+	}
+	fields := entries[0].ContextMap()
+	if fields["db_queries"] != int64(4) {
+		t.Errorf("db_queries = %v, want 4", fields["db_queries"])
+	}
+	if d, ok := fields["db_slowest"].(time.Duration); !ok || d < 5*time.Millisecond {
+		t.Errorf("db_slowest = %v, want >= 5ms", fields["db_slowest"])
+	}
+	if d, ok := fields["db_time"].(time.Duration); !ok || d < 5*time.Millisecond {
+		t.Errorf("db_time = %v, want >= 5ms", fields["db_time"])
+	}
+	want := []any{map[string]any{"fingerprint": "SELECT * FROM users WHERE id = ?", "count": 3}}
+	if !reflect.DeepEqual(fields["db_repeated"], want) {
+		t.Errorf("db_repeated = %v, want %v", fields["db_repeated"], want)
+	}
+}
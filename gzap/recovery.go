@@ -0,0 +1,111 @@
+package gzap
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+	"sync"
+	"syscall"
+
+	"github.com/emicklei/go-restful/v3"
+
+	"github.com/nova-clouds/restful-contrib/problem"
+)
+
+// PanicAttribute the request attribute which is set to true by Recovery when recovered from panic.
+const PanicAttribute = "gzap.panic"
+
+// Panicked report whether the request is recovered from panic by Recovery.
+func Panicked(req *restful.Request) bool {
+	b, _ := req.Attribute(PanicAttribute).(bool)
+	return b
+}
+
+// PanicReporter reports the recovered panic, like sending to an error tracker.
+// stack is the stack when the panic recovered.
+type PanicReporter func(req *restful.Request, err any, stack []byte)
+
+// WithRecoveryResponder optional custom the responder which writes the response when recovered from panic.
+// default: the 500 Internal Server Error problem details with the trace id.
+func WithRecoveryResponder(f func(req *restful.Request, resp *restful.Response, err any)) Option {
+	return func(c *Config) {
+		if f != nil {
+			c.recoveryResponder = f
+		}
+	}
+}
+
+// WithPanicReporter optional add the panic reporters, which are called after the panic logged.
+func WithPanicReporter(reporters ...PanicReporter) Option {
+	return func(c *Config) {
+		for _, r := range reporters {
+			if r != nil {
+				c.panicReporters = append(c.panicReporters, r)
+			}
+		}
+	}
+}
+
+// WithPanicStats optional custom the panic metrics.
+func WithPanicStats(s *PanicStats) Option {
+	return func(c *Config) {
+		c.panicStats = s
+	}
+}
+
+func recoveryResponder(req *restful.Request, resp *restful.Response, err any) {
+	problem.Write(req, resp, http.StatusInternalServerError, "") // nolint: errcheck
+}
+
+// isBrokenPipe check for a broken connection, as it is not really a
+// condition that warrants a panic stack trace.
+func isBrokenPipe(err any) bool {
+	e, ok := err.(error)
+	return ok && (errors.Is(e, syscall.EPIPE) || errors.Is(e, syscall.ECONNRESET))
+}
+
+// PanicStats the panic metrics, counts the recovered panics in total and per route.
+// It is safe for concurrent use.
+type PanicStats struct {
+	mu          sync.Mutex
+	total       uint64
+	brokenPipes uint64
+	routes      map[string]uint64
+}
+
+// NewPanicStats new a panic metrics.
+func NewPanicStats() *PanicStats {
+	return &PanicStats{routes: make(map[string]uint64)}
+}
+
+func (s *PanicStats) incr(method, route string, brokenPipe bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total++
+	if brokenPipe {
+		s.brokenPipes++
+	}
+	s.routes[method+" "+route]++
+}
+
+// Total returns the total number of the recovered panics.
+func (s *PanicStats) Total() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.total
+}
+
+// BrokenPipes returns the number of the recovered broken connections.
+func (s *PanicStats) BrokenPipes() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.brokenPipes
+}
+
+// Routes returns a copy of the number of the recovered panics per route,
+// the key is like "GET /users/{id}".
+func (s *PanicStats) Routes() map[string]uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return maps.Clone(s.routes)
+}
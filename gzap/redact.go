@@ -0,0 +1,329 @@
+package gzap
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// RedactOption redactor option
+type RedactOption func(r *Redactor)
+
+// WithRedactMask optional custom the mask which replaces the sensitive value.
+// default: "******"
+func WithRedactMask(mask string) RedactOption {
+	return func(r *Redactor) {
+		r.mask = mask
+	}
+}
+
+// WithRedactHeaders optional add the header denylist, the header name is case-insensitive.
+func WithRedactHeaders(names ...string) RedactOption {
+	return func(r *Redactor) {
+		for _, name := range names {
+			r.headers[http.CanonicalHeaderKey(name)] = struct{}{}
+		}
+	}
+}
+
+// WithRedactFields optional add the JSON keys and form fields to mask at any depth,
+// the field name is case-insensitive.
+func WithRedactFields(names ...string) RedactOption {
+	return func(r *Redactor) {
+		for _, name := range names {
+			r.fields[strings.ToLower(name)] = struct{}{}
+		}
+	}
+}
+
+// WithRedactQueryParams optional add the query parameters to mask, the name is case-insensitive.
+func WithRedactQueryParams(names ...string) RedactOption {
+	return func(r *Redactor) {
+		for _, name := range names {
+			r.queryParams[strings.ToLower(name)] = struct{}{}
+		}
+	}
+}
+
+// WithRedactJSONPaths optional add the JSON paths to mask,
+// the path is dot separated keys from the root, "*" matches any key or array index.
+// like "user.password", "cards.*.number".
+func WithRedactJSONPaths(paths ...string) RedactOption {
+	return func(r *Redactor) {
+		for _, path := range paths {
+			if path != "" {
+				r.paths = append(r.paths, strings.Split(path, "."))
+			}
+		}
+	}
+}
+
+// WithRedactPatterns optional add the regex rules, the matched text is replaced by the mask.
+func WithRedactPatterns(patterns ...*regexp.Regexp) RedactOption {
+	return func(r *Redactor) {
+		for _, p := range patterns {
+			if p != nil {
+				r.patterns = append(r.patterns, p)
+			}
+		}
+	}
+}
+
+// WithRedactCardNumbers optional mask the payment card numbers which pass the Luhn check.
+func WithRedactCardNumbers(b bool) RedactOption {
+	return func(r *Redactor) {
+		r.cardNumbers = b
+	}
+}
+
+// Redactor redacts the sensitive headers and the fields of the bodies.
+// It applies to the request/response body, the query, the headers and the debug curl.
+type Redactor struct {
+	mask        string
+	headers     map[string]struct{}
+	fields      map[string]struct{}
+	queryParams map[string]struct{}
+	paths       [][]string
+	patterns    []*regexp.Regexp
+	cardNumbers bool
+}
+
+// NewRedactor new a redactor.
+func NewRedactor(opts ...RedactOption) *Redactor {
+	r := &Redactor{
+		mask:        "******",
+		headers:     make(map[string]struct{}),
+		fields:      make(map[string]struct{}),
+		queryParams: make(map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// DefaultRedactor returns a redactor with the common sensitive headers, fields,
+// query parameters and card numbers.
+func DefaultRedactor(opts ...RedactOption) *Redactor {
+	return NewRedactor(append([]RedactOption{
+		WithRedactHeaders("Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"),
+		WithRedactFields(
+			"password", "passwd", "secret", "token", "access_token", "refresh_token",
+			"api_key", "apikey", "card_number", "cardNumber", "cvv",
+		),
+		WithRedactQueryParams(
+			"token", "access_token", "refresh_token", "id_token", "api_key", "apikey",
+			"password", "secret", "signature", "sig", "email",
+		),
+		WithRedactCardNumbers(true),
+	}, opts...)...)
+}
+
+// Header returns a copy of the header with the denylist header values masked,
+// the regex rules apply to the other values.
+func (r *Redactor) Header(h http.Header) http.Header {
+	h = h.Clone()
+	for name, vs := range h {
+		_, deny := r.headers[http.CanonicalHeaderKey(name)]
+		for i := range vs {
+			if deny {
+				vs[i] = r.mask
+			} else {
+				vs[i] = r.String(vs[i])
+			}
+		}
+	}
+	return h
+}
+
+// Body returns the masked body with the content type,
+// the JSON and form body masks the fields and JSON paths, the regex rules apply to all.
+// It fails closed: the JSON or form body which can not be parsed, like truncated by the body limit,
+// is replaced by the mask entirely if there are field or JSON path rules.
+func (r *Redactor) Body(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		if s, ok := r.form(body); ok {
+			return r.String(s)
+		}
+		return r.failClosed(body)
+	case isJSON(mediaType) || (mediaType == "" && looksJSON(body)):
+		if s, ok := r.json(body); ok {
+			return r.String(s)
+		}
+		return r.failClosed(body)
+	}
+	return r.String(string(body))
+}
+
+// failClosed returns the mask for the unparsable structured body if the field rules may apply.
+func (r *Redactor) failClosed(body []byte) string {
+	if len(r.fields) > 0 || len(r.paths) > 0 {
+		return r.mask
+	}
+	return r.String(string(body))
+}
+
+// Query returns the raw query with the values of the denylist parameters masked,
+// the regex rules apply to the other values, the order of the parameters is kept.
+func (r *Redactor) Query(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	b := strings.Builder{}
+	b.Grow(len(rawQuery))
+	for i, part := range strings.Split(rawQuery, "&") {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		key, value, hasValue := strings.Cut(part, "=")
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		b.WriteString(key)
+		if !hasValue {
+			continue
+		}
+		b.WriteByte('=')
+		if _, ok := r.queryParams[strings.ToLower(name)]; ok {
+			value = url.QueryEscape(r.mask)
+		} else if v, err := url.QueryUnescape(value); err == nil {
+			if masked := r.String(v); masked != v {
+				value = url.QueryEscape(masked)
+			}
+		}
+		b.WriteString(value)
+	}
+	return b.String()
+}
+
+// String returns the string with the regex rules applied.
+func (r *Redactor) String(s string) string {
+	for _, p := range r.patterns {
+		s = p.ReplaceAllLiteralString(s, r.mask)
+	}
+	if r.cardNumbers {
+		s = cardNumberPattern.ReplaceAllStringFunc(s, func(v string) string {
+			if luhn(v) {
+				return r.mask
+			}
+			return v
+		})
+	}
+	return s
+}
+
+func (r *Redactor) form(body []byte) (string, bool) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return "", false
+	}
+	for k, vs := range values {
+		if _, ok := r.fields[strings.ToLower(k)]; ok {
+			for i := range vs {
+				vs[i] = r.mask
+			}
+		}
+	}
+	return values.Encode(), true
+}
+
+func (r *Redactor) json(body []byte) (string, bool) {
+	var v any
+
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return "", false
+	}
+	v = r.walk(v, nil)
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+func (r *Redactor) walk(v any, path []string) any {
+	switch vv := v.(type) {
+	case map[string]any:
+		for k, sub := range vv {
+			subPath := append(path[:len(path):len(path)], k)
+			if _, ok := r.fields[strings.ToLower(k)]; ok || r.matchPath(subPath) {
+				vv[k] = r.mask
+			} else {
+				vv[k] = r.walk(sub, subPath)
+			}
+		}
+	case []any:
+		for i, sub := range vv {
+			subPath := append(path[:len(path):len(path)], "*")
+			if r.matchPath(subPath) {
+				vv[i] = r.mask
+			} else {
+				vv[i] = r.walk(sub, subPath)
+			}
+		}
+	}
+	return v
+}
+
+func (r *Redactor) matchPath(path []string) bool {
+	for _, p := range r.paths {
+		if len(p) != len(path) {
+			continue
+		}
+		matched := true
+		for i := range p {
+			if p[i] != "*" && p[i] != path[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// looksJSON reports whether the body starts like a JSON object or array, it may be truncated.
+func looksJSON(body []byte) bool {
+	body = bytes.TrimLeft(body, " \t\r\n")
+	return len(body) > 0 && (body[0] == '{' || body[0] == '[')
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+var cardNumberPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+
+// luhn reports whether the digits of s pass the Luhn checksum.
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
@@ -0,0 +1,98 @@
+package gzap
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+func TestRedactor(t *testing.T) {
+	r := DefaultRedactor(
+		WithRedactJSONPaths("user.email", "items.*.serial"),
+		WithRedactPatterns(regexp.MustCompile(`sk_live_[0-9a-zA-Z]+`)),
+	)
+
+	h := http.Header{}
+	h.Set("Authorization", "Bearer abc")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Stripe-Key", "sk_live_abc123")
+	h.Set("X-Card", "4111 1111 1111 1111")
+	got := r.Header(h)
+	if got.Get("Authorization") != "******" || got.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected redacted header: %v", got)
+	}
+	// the regex rules apply to the headers not in the denylist.
+	if got.Get("X-Stripe-Key") != "******" || got.Get("X-Card") != "******" {
+		t.Errorf("the pattern rules should apply to the header values: %v", got)
+	}
+	if h.Get("Authorization") != "Bearer abc" {
+		t.Errorf("origin header should not be changed")
+	}
+
+	var tests = []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{
+			name:        "json",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"user":{"email":"a@b.c","name":"bob","Password":"123"},"items":[{"serial":"s1","id":1}]}`,
+			want:        `{"items":[{"id":1,"serial":"******"}],"user":{"Password":"******","email":"******","name":"bob"}}`,
+		},
+		{
+			name:        "form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "name=bob&password=123",
+			want:        "name=bob&password=%2A%2A%2A%2A%2A%2A",
+		},
+		{
+			name:        "text",
+			contentType: "text/plain",
+			body:        "key sk_live_abc123, card 4111 1111 1111 1111, order 1234567890123",
+			want:        "key ******, card ******, order 1234567890123",
+		},
+		{
+			name:        "invalid json",
+			contentType: "application/json",
+			body:        `{"token":`,
+			want:        `******`,
+		},
+		{
+			name: "truncated json without content type",
+			body: ` {"name":"bob","password":"hun`,
+			want: `******`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Body(tt.contentType, []byte(tt.body)); got != tt.want {
+				t.Errorf("Body() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactorQuery(t *testing.T) {
+	r := DefaultRedactor(WithRedactQueryParams("X-Session"))
+
+	var tests = []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"empty", "", ""},
+		{"keep order", "b=2&token=abc&a=1", "b=2&token=%2A%2A%2A%2A%2A%2A&a=1"},
+		{"case-insensitive", "Access_Token=abc&x-session=1", "Access_Token=%2A%2A%2A%2A%2A%2A&x-session=%2A%2A%2A%2A%2A%2A"},
+		{"card number", "q=4111+1111+1111+1111&flag", "q=%2A%2A%2A%2A%2A%2A&flag"},
+		{"escaped key", "api%5Fkey=abc", "api%5Fkey=%2A%2A%2A%2A%2A%2A"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Query(tt.query); got != tt.want {
+				t.Errorf("Query() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
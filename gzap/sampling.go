@@ -0,0 +1,79 @@
+package gzap
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SampleRule the sampling and rate limit rule of the route.
+type SampleRule struct {
+	// Method the http method, empty matches any method.
+	Method string
+	// Route the route template, which is SelectedRoute().Path(), like "/users/{id}".
+	Route string
+	// Every logs 1 in every n requests, <=1 mean logs all.
+	Every uint64
+	// Rate the max logs per second, <=0 mean not limit.
+	Rate int64
+}
+
+// Sampler samples and rate limits the logging per route.
+// It only applies to the requests logged below zap.WarnLevel, so the errors and
+// the slow requests are always logged.
+// It is safe for concurrent use.
+type Sampler struct {
+	rules map[string]*sampleState
+}
+
+type sampleState struct {
+	SampleRule
+	counter atomic.Uint64
+
+	mu     sync.Mutex
+	window int64 // the unix second of the rate window
+	count  int64 // the logs in the rate window
+}
+
+// NewSampler new a sampler with the rules,
+// the later rule overrides the earlier one with the same method and route.
+func NewSampler(rules ...SampleRule) *Sampler {
+	s := &Sampler{
+		rules: make(map[string]*sampleState, len(rules)),
+	}
+	for _, r := range rules {
+		s.rules[r.Method+" "+r.Route] = &sampleState{SampleRule: r}
+	}
+	return s
+}
+
+// Allow reports whether the request of the method and route should be logged.
+func (s *Sampler) Allow(method, route string) bool {
+	st, ok := s.rules[method+" "+route]
+	if !ok {
+		if st, ok = s.rules[" "+route]; !ok {
+			return true
+		}
+	}
+	return st.allow(time.Now())
+}
+
+func (s *sampleState) allow(now time.Time) bool {
+	if s.Every > 1 && (s.counter.Add(1)-1)%s.Every != 0 {
+		return false
+	}
+	if s.Rate <= 0 {
+		return true
+	}
+	window := now.Unix()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.window != window {
+		s.window, s.count = window, 0
+	}
+	if s.count >= s.Rate {
+		return false
+	}
+	s.count++
+	return true
+}
@@ -0,0 +1,174 @@
+package gzap
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SlowStack the stack to capture when a request crosses the slow threshold.
+type SlowStack int
+
+const (
+	// SlowStackNone not capture the stack.
+	SlowStackNone SlowStack = iota
+	// SlowStackHandler captures the stack of the goroutine which serves the request.
+	SlowStackHandler
+	// SlowStackAll captures the stack of all goroutines.
+	SlowStackAll
+)
+
+// slowThreshold returns the slow threshold of the method and route.
+func (c *Config) slowThreshold(method, route string) time.Duration {
+	if d, ok := c.routeSlowThresholds[method+" "+route]; ok {
+		return d
+	}
+	if d, ok := c.routeSlowThresholds[" "+route]; ok {
+		return d
+	}
+	return c.slowThresholdDefault
+}
+
+// slowWatcher captures the stack when the request crosses the slow threshold.
+type slowWatcher struct {
+	timer     *time.Timer
+	mu        sync.Mutex
+	stack     []byte
+	truncated bool
+}
+
+// watchSlow starts to watch the current goroutine, it captures the stack after threshold
+// if the limiter allows the key.
+func watchSlow(threshold time.Duration, mode SlowStack, limiter *slowLimiter, key string) *slowWatcher {
+	w := &slowWatcher{}
+	gid := currentGoroutineId()
+	w.timer = time.AfterFunc(threshold, func() {
+		if !limiter.allow(key, time.Now()) {
+			return
+		}
+		var stack []byte
+		var truncated bool
+		if mode == SlowStackHandler {
+			stack, truncated = handlerStack(gid)
+		} else {
+			stack, truncated = allStack()
+		}
+		w.mu.Lock()
+		w.stack, w.truncated = stack, truncated
+		w.mu.Unlock()
+	})
+	return w
+}
+
+// Stop stops to watch, it returns the stack captured and whether it is truncated.
+func (w *slowWatcher) Stop() ([]byte, bool) {
+	w.timer.Stop()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stack, w.truncated
+}
+
+// slowLimiter limits the stack captures to one per key per interval.
+type slowLimiter struct {
+	interval time.Duration // <=0: mean not limit
+	last     sync.Map      // key -> *atomic.Int64, unix nano of the last capture
+}
+
+func (l *slowLimiter) allow(key string, now time.Time) bool {
+	if l.interval <= 0 {
+		return true
+	}
+	v, _ := l.last.LoadOrStore(key, new(atomic.Int64))
+	last := v.(*atomic.Int64)
+	prev := last.Load()
+	if prev != 0 && now.UnixNano()-prev < int64(l.interval) {
+		return false
+	}
+	return last.CompareAndSwap(prev, now.UnixNano())
+}
+
+// currentGoroutineId returns the goroutine id, parse from "goroutine 18 [running]:".
+func currentGoroutineId() []byte {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i > 0 {
+		if _, err := strconv.ParseUint(string(buf[:i]), 10, 64); err == nil {
+			return buf[:i]
+		}
+	}
+	return nil
+}
+
+// maxStackSize the max bytes of the stack of all goroutines.
+const maxStackSize = 1 << 20
+
+// maxStackScanSize the max bytes of the stack of all goroutines scanned for the handler goroutine,
+// only the handler stack is kept.
+const maxStackScanSize = 64 << 20
+
+// allStack returns the stack of all goroutines, at most maxStackSize bytes,
+// truncated reports whether the stack is cut.
+func allStack() (stack []byte, truncated bool) {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n], false
+		}
+		if len(buf) >= maxStackSize {
+			return buf[:n], true
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// handlerStack returns the stack of the goroutine, the stack of another goroutine can't be
+// captured alone, so the stack of all goroutines grows until the goroutine is found,
+// at most maxStackScanSize bytes, truncated reports whether the goroutine is not found or cut.
+func handlerStack(gid []byte) (stack []byte, truncated bool) {
+	if gid == nil {
+		return nil, true
+	}
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		full := n == len(buf)
+		stack, last := goroutineStack(buf[:n], gid)
+		if stack != nil && (!full || !last) {
+			return bytes.Clone(stack), false
+		}
+		if !full {
+			// the goroutine has exited.
+			return nil, false
+		}
+		if len(buf) >= maxStackScanSize {
+			return bytes.Clone(stack), true
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// goroutineStack returns the stack of the goroutine from the stack of all goroutines,
+// last reports whether it is the last one, which may be cut.
+func goroutineStack(all, gid []byte) (stack []byte, last bool) {
+	prefix := append(append([]byte("goroutine "), gid...), ' ')
+	sep := []byte("\n\n")
+	for len(all) > 0 {
+		i := bytes.Index(all, sep)
+		if bytes.HasPrefix(all, prefix) {
+			if i < 0 {
+				return all, true
+			}
+			return all[:i], false
+		}
+		if i < 0 {
+			break
+		}
+		all = all[i+len(sep):]
+	}
+	return nil, false
+}
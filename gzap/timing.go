@@ -0,0 +1,126 @@
+package gzap
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"go.uber.org/zap/zapcore"
+)
+
+// WithFilterTiming optional records the duration of each filter after the logger in the chain,
+// the duration of a filter excludes the filters and the handler after it.
+func WithFilterTiming(b bool) Option {
+	return func(c *Config) {
+		c.filterTiming = b
+	}
+}
+
+// filterTiming the duration of a filter.
+type filterTiming struct {
+	name     string
+	duration time.Duration
+}
+
+// chainTiming records the durations of the rest of the filter chain.
+type chainTiming struct {
+	filters []filterTiming // inclusive duration until done
+	handler time.Duration  // -1: the handler is not reached
+}
+
+// timedChain returns a copy of the rest of the chain which records the durations,
+// the filters of the chain is shared by the requests, so never modify it.
+func timedChain(chain *restful.FilterChain, filters bool) (*restful.FilterChain, *chainTiming) {
+	ct := &chainTiming{handler: -1}
+	target := chain.Target
+	timed := &restful.FilterChain{
+		Filters:       chain.Filters[chain.Index:],
+		Target:        func(req *restful.Request, resp *restful.Response) { ct.timeHandler(target, req, resp) },
+		ParameterDocs: chain.ParameterDocs,
+		Operation:     chain.Operation,
+	}
+	if filters && len(timed.Filters) > 0 {
+		rest := timed.Filters
+		ct.filters = make([]filterTiming, len(rest))
+		timed.Filters = make([]restful.FilterFunction, len(rest))
+		for i, f := range rest {
+			ct.filters[i].name = filterName(f)
+			timed.Filters[i] = func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+				start := time.Now()
+				defer func() { ct.filters[i].duration = time.Since(start) }()
+				f(req, resp, chain)
+			}
+		}
+	}
+	return timed, ct
+}
+
+func (ct *chainTiming) timeHandler(target restful.RouteFunction, req *restful.Request, resp *restful.Response) {
+	start := time.Now()
+	defer func() { ct.handler = time.Since(start) }()
+	target(req, resp)
+}
+
+// MarshalLogArray implements zapcore.ArrayMarshaler, the duration of each filter excludes the rest.
+func (ct *chainTiming) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for i, f := range ct.filters {
+		d := f.duration
+		if i+1 < len(ct.filters) {
+			d -= ct.filters[i+1].duration
+		} else if ct.handler > 0 {
+			d -= ct.handler
+		}
+		err := enc.AppendObject(zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc.AddString("name", f.name)
+			enc.AddDuration("duration", max(d, 0))
+			return nil
+		}))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var filterNames sync.Map // uintptr -> string
+
+// filterName returns the short function name of the filter, like "authj.Authorizer".
+func filterName(f restful.FilterFunction) string {
+	pc := reflect.ValueOf(f).Pointer()
+	if name, ok := filterNames.Load(pc); ok {
+		return name.(string)
+	}
+	name := "unknown"
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		name = fn.Name()
+		if i := strings.LastIndexByte(name, '/'); i >= 0 {
+			name = name[i+1:]
+		}
+		// trim the closure suffix, like ".func1", ".func1.2".
+		for {
+			i := strings.LastIndexByte(name, '.')
+			if i < 0 || !isClosureSuffix(name[i+1:]) {
+				break
+			}
+			name = name[:i]
+		}
+	}
+	filterNames.Store(pc, name)
+	return name
+}
+
+func isClosureSuffix(s string) bool {
+	s = strings.TrimPrefix(s, "func")
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,158 @@
+// Package interceptor provides the response writer interception which preserves
+// the optional interfaces http.Flusher, http.Hijacker, io.ReaderFrom, http.Pusher
+// and http.CloseNotifier.
+package interceptor
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Recorder records the response written through the wrapped response writer.
+type Recorder struct {
+	start     time.Time
+	status    int
+	written   atomic.Int64
+	firstByte atomic.Int64 // unix nano of the first byte written, 0 mean not written
+	body      io.Writer    // optional, the copy of the body
+}
+
+// Status returns the status code written, 0 if the header is not written yet.
+func (r *Recorder) Status() int { return r.status }
+
+// Written returns the bytes of the body written.
+func (r *Recorder) Written() int64 { return r.written.Load() }
+
+// Start returns the time when the response writer was wrapped.
+func (r *Recorder) Start() time.Time { return r.start }
+
+// TimeToFirstByte returns the duration from the start to the first byte written or flushed,
+// 0 if nothing is written yet.
+func (r *Recorder) TimeToFirstByte() time.Duration {
+	if at := r.firstByte.Load(); at != 0 {
+		return time.Unix(0, at).Sub(r.start)
+	}
+	return 0
+}
+
+func (r *Recorder) markFirstByte() {
+	if r.firstByte.Load() == 0 {
+		r.firstByte.CompareAndSwap(0, time.Now().UnixNano())
+	}
+}
+
+// RecorderOf returns the recorder of the response writer wrapped by Wrap, the wrappers
+// of other middlewares are unwrapped through their Unwrap method.
+func RecorderOf(w http.ResponseWriter) (*Recorder, bool) {
+	for w != nil {
+		if r, ok := w.(interface{ recorder() *Recorder }); ok {
+			return r.recorder(), true
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = u.Unwrap()
+	}
+	return nil, false
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	rec *Recorder
+}
+
+func (w *responseWriter) recorder() *Recorder { return w.rec }
+
+// Unwrap returns the original response writer, which is used by http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.rec.status == 0 {
+		w.rec.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.rec.status == 0 {
+		w.rec.status = http.StatusOK
+	}
+	if len(b) > 0 {
+		w.rec.markFirstByte()
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.rec.written.Add(int64(n))
+	if w.rec.body != nil && n > 0 {
+		w.rec.body.Write(b[:n]) // nolint: errcheck
+	}
+	return n, err
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	if w.rec.status == 0 {
+		w.rec.status = http.StatusOK
+	}
+	if len(s) > 0 {
+		w.rec.markFirstByte()
+	}
+	n, err := io.WriteString(w.ResponseWriter, s)
+	w.rec.written.Add(int64(n))
+	if w.rec.body != nil && n > 0 {
+		io.WriteString(w.rec.body, s[:n]) // nolint: errcheck
+	}
+	return n, err
+}
+
+type flusher struct{ *responseWriter }
+
+func (w flusher) Flush() {
+	if w.rec.status == 0 {
+		w.rec.status = http.StatusOK
+	}
+	w.rec.markFirstByte()
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+type hijacker struct{ *responseWriter }
+
+func (w hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+type readerFrom struct{ *responseWriter }
+
+// ReadFrom uses the io.ReaderFrom of the original response writer, like sendfile,
+// except the copy of the body is required. The src is passed unchanged, so the concrete
+// type like *os.File is kept, the first byte is marked when the call returns.
+func (w readerFrom) ReadFrom(src io.Reader) (int64, error) {
+	if w.rec.body != nil {
+		// hide ReadFrom, so io.Copy uses Write which copies the body.
+		return io.Copy(struct{ io.Writer }{w.responseWriter}, src)
+	}
+	if w.rec.status == 0 {
+		w.rec.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+	if n > 0 {
+		w.rec.markFirstByte()
+	}
+	w.rec.written.Add(n)
+	return n, err
+}
+
+type pusher struct{ *responseWriter }
+
+func (w pusher) Push(target string, opts *http.PushOptions) error {
+	return w.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+type closeNotifier struct{ *responseWriter }
+
+func (w closeNotifier) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify() // nolint: staticcheck
+}
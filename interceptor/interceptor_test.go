@@ -0,0 +1,210 @@
+package interceptor
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type plainWriter struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+func (w *plainWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = make(http.Header)
+	}
+	return w.header
+}
+func (w *plainWriter) Write(b []byte) (int, error) { return w.body.Write(b) }
+func (w *plainWriter) WriteHeader(code int)        { w.status = code }
+
+type flushWriter struct {
+	*plainWriter
+	flushed int
+}
+
+func (w *flushWriter) Flush() { w.flushed++ }
+
+type hijackWriter struct{ *plainWriter }
+
+func (w *hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { return nil, nil, nil }
+
+type readFromWriter struct {
+	*plainWriter
+	readFrom int
+	src      io.Reader
+}
+
+func (w *readFromWriter) ReadFrom(src io.Reader) (int64, error) {
+	w.readFrom++
+	w.src = src
+	return io.Copy(&w.body, src)
+}
+
+type pushWriter struct{ *plainWriter }
+
+func (w *pushWriter) Push(string, *http.PushOptions) error { return nil }
+
+type closeNotifyWriter struct{ *plainWriter }
+
+func (w *closeNotifyWriter) CloseNotify() <-chan bool { return nil }
+
+func TestWrap(t *testing.T) {
+	p := &plainWriter{}
+	tests := []struct {
+		name          string
+		w             http.ResponseWriter
+		flusher       bool
+		hijacker      bool
+		readerFrom    bool
+		pusher        bool
+		closeNotifier bool
+	}{
+		{"plain", p, false, false, false, false, false},
+		{"flusher", &flushWriter{plainWriter: p}, true, false, false, false, false},
+		{"hijacker", &hijackWriter{p}, false, true, false, false, false},
+		{"readerFrom", &readFromWriter{plainWriter: p}, false, false, true, false, false},
+		{"pusher", &pushWriter{p}, false, false, false, true, false},
+		{"closeNotifier", &closeNotifyWriter{p}, false, false, false, false, true},
+		{"flusher+hijacker", struct {
+			*flushWriter
+			http.Hijacker
+		}{&flushWriter{plainWriter: p}, &hijackWriter{p}}, true, true, false, false, false},
+		{"flusher+readerFrom", struct {
+			*flushWriter
+			io.ReaderFrom
+		}{&flushWriter{plainWriter: p}, &readFromWriter{plainWriter: p}}, true, false, true, false, false},
+		{"hijacker+readerFrom", struct {
+			*hijackWriter
+			io.ReaderFrom
+		}{&hijackWriter{p}, &readFromWriter{plainWriter: p}}, false, true, true, false, false},
+		// like the http/2 response writer.
+		{"flusher+pusher+closeNotifier", struct {
+			*flushWriter
+			http.Pusher
+			http.CloseNotifier
+		}{&flushWriter{plainWriter: p}, &pushWriter{p}, &closeNotifyWriter{p}}, true, false, false, true, true},
+		{"all", struct {
+			http.ResponseWriter
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+			http.CloseNotifier
+		}{p, &flushWriter{plainWriter: p}, &hijackWriter{p}, &readFromWriter{plainWriter: p}, &pushWriter{p}, &closeNotifyWriter{p}}, true, true, true, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, _ := Wrap(tt.w, nil)
+			if _, ok := w.(http.Flusher); ok != tt.flusher {
+				t.Errorf("http.Flusher = %v, want %v", ok, tt.flusher)
+			}
+			if _, ok := w.(http.Hijacker); ok != tt.hijacker {
+				t.Errorf("http.Hijacker = %v, want %v", ok, tt.hijacker)
+			}
+			if _, ok := w.(io.ReaderFrom); ok != tt.readerFrom {
+				t.Errorf("io.ReaderFrom = %v, want %v", ok, tt.readerFrom)
+			}
+			if _, ok := w.(http.Pusher); ok != tt.pusher {
+				t.Errorf("http.Pusher = %v, want %v", ok, tt.pusher)
+			}
+			if _, ok := w.(http.CloseNotifier); ok != tt.closeNotifier {
+				t.Errorf("http.CloseNotifier = %v, want %v", ok, tt.closeNotifier)
+			}
+			if u, ok := w.(interface{ Unwrap() http.ResponseWriter }); !ok || u.Unwrap() != tt.w {
+				t.Errorf("Unwrap() should return the original response writer")
+			}
+		})
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	t.Run("write", func(t *testing.T) {
+		var body bytes.Buffer
+
+		rw := httptest.NewRecorder()
+		w, rec := Wrap(rw, &body)
+		if rec.Status() != 0 || rec.Written() != 0 || rec.TimeToFirstByte() != 0 {
+			t.Fatalf("recorder should be empty before writing")
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("hello "))
+		_, _ = io.WriteString(w, "world")
+		w.(http.Flusher).Flush()
+
+		if got := rec.Status(); got != http.StatusCreated {
+			t.Errorf("Status() = %d, want %d", got, http.StatusCreated)
+		}
+		if got := rec.Written(); got != 11 {
+			t.Errorf("Written() = %d, want 11", got)
+		}
+		if rec.TimeToFirstByte() <= 0 {
+			t.Errorf("TimeToFirstByte() should be positive")
+		}
+		if got := body.String(); got != "hello world" {
+			t.Errorf("body = %q, want %q", got, "hello world")
+		}
+		if !rw.Flushed || rw.Body.String() != "hello world" {
+			t.Errorf("original response writer = %q, flushed %v", rw.Body.String(), rw.Flushed)
+		}
+	})
+	t.Run("read from", func(t *testing.T) {
+		rf := &readFromWriter{plainWriter: &plainWriter{}}
+		w, rec := Wrap(rf, nil)
+		src := strings.NewReader("sendfile")
+		n, err := w.(io.ReaderFrom).ReadFrom(src)
+		if err != nil || n != 8 {
+			t.Fatalf("ReadFrom() = %d, %v", n, err)
+		}
+		if rf.readFrom != 1 {
+			t.Errorf("ReadFrom of the original response writer should be used")
+		}
+		if rf.src != src {
+			t.Errorf("src should be passed unchanged, got %T", rf.src)
+		}
+		if rec.Status() != http.StatusOK || rec.Written() != 8 || rec.TimeToFirstByte() <= 0 {
+			t.Errorf("recorder = %d, %d, %v", rec.Status(), rec.Written(), rec.TimeToFirstByte())
+		}
+	})
+	t.Run("read from with body", func(t *testing.T) {
+		var body bytes.Buffer
+
+		rf := &readFromWriter{plainWriter: &plainWriter{}}
+		w, rec := Wrap(rf, &body)
+		n, err := w.(io.ReaderFrom).ReadFrom(strings.NewReader("sendfile"))
+		if err != nil || n != 8 {
+			t.Fatalf("ReadFrom() = %d, %v", n, err)
+		}
+		if body.String() != "sendfile" || rf.body.String() != "sendfile" {
+			t.Errorf("body = %q, original = %q", body.String(), rf.body.String())
+		}
+		if rec.Written() != 8 {
+			t.Errorf("Written() = %d, want 8", rec.Written())
+		}
+	})
+}
+
+type otherWriter struct{ http.ResponseWriter }
+
+func (w *otherWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func TestRecorderOf(t *testing.T) {
+	if _, ok := RecorderOf(httptest.NewRecorder()); ok {
+		t.Errorf("RecorderOf() of an unwrapped response writer should be false")
+	}
+	w, rec := Wrap(httptest.NewRecorder(), nil)
+	if got, ok := RecorderOf(w); !ok || got != rec {
+		t.Errorf("RecorderOf() should return the recorder of Wrap")
+	}
+	if got, ok := RecorderOf(&otherWriter{w}); !ok || got != rec {
+		t.Errorf("RecorderOf() should unwrap the other wrappers")
+	}
+}
@@ -0,0 +1,249 @@
+package interceptor
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// the optional interfaces of the response writer.
+const (
+	isFlusher = 1 << iota
+	isHijacker
+	isReaderFrom
+	isPusher
+	isCloseNotifier
+)
+
+// Wrap wraps the response writer, the returned response writer implements
+// http.Flusher, http.Hijacker, io.ReaderFrom, http.Pusher and http.CloseNotifier
+// if and only if w implements them, body is optional, it receives a copy of the body written.
+func Wrap(w http.ResponseWriter, body io.Writer) (http.ResponseWriter, *Recorder) {
+	rec := &Recorder{start: time.Now(), body: body}
+	base := &responseWriter{w, rec}
+
+	flags := 0
+	if _, ok := w.(http.Flusher); ok {
+		flags |= isFlusher
+	}
+	if _, ok := w.(http.Hijacker); ok {
+		flags |= isHijacker
+	}
+	if _, ok := w.(io.ReaderFrom); ok {
+		flags |= isReaderFrom
+	}
+	if _, ok := w.(http.Pusher); ok {
+		flags |= isPusher
+	}
+	if _, ok := w.(http.CloseNotifier); ok { // nolint: staticcheck
+		flags |= isCloseNotifier
+	}
+	switch flags {
+	case isFlusher | isHijacker | isReaderFrom | isPusher | isCloseNotifier:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+			http.CloseNotifier // nolint: staticcheck
+		}{base, flusher{base}, hijacker{base}, readerFrom{base}, pusher{base}, closeNotifier{base}}, rec
+	case isHijacker | isReaderFrom | isPusher | isCloseNotifier:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+			http.CloseNotifier // nolint: staticcheck
+		}{base, hijacker{base}, readerFrom{base}, pusher{base}, closeNotifier{base}}, rec
+	case isFlusher | isReaderFrom | isPusher | isCloseNotifier:
+		return struct {
+			*responseWriter
+			http.Flusher
+			io.ReaderFrom
+			http.Pusher
+			http.CloseNotifier // nolint: staticcheck
+		}{base, flusher{base}, readerFrom{base}, pusher{base}, closeNotifier{base}}, rec
+	case isReaderFrom | isPusher | isCloseNotifier:
+		return struct {
+			*responseWriter
+			io.ReaderFrom
+			http.Pusher
+			http.CloseNotifier // nolint: staticcheck
+		}{base, readerFrom{base}, pusher{base}, closeNotifier{base}}, rec
+	case isFlusher | isHijacker | isPusher | isCloseNotifier:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+			http.CloseNotifier // nolint: staticcheck
+		}{base, flusher{base}, hijacker{base}, pusher{base}, closeNotifier{base}}, rec
+	case isHijacker | isPusher | isCloseNotifier:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			http.Pusher
+			http.CloseNotifier // nolint: staticcheck
+		}{base, hijacker{base}, pusher{base}, closeNotifier{base}}, rec
+	case isFlusher | isPusher | isCloseNotifier:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Pusher
+			http.CloseNotifier // nolint: staticcheck
+		}{base, flusher{base}, pusher{base}, closeNotifier{base}}, rec
+	case isPusher | isCloseNotifier:
+		return struct {
+			*responseWriter
+			http.Pusher
+			http.CloseNotifier // nolint: staticcheck
+		}{base, pusher{base}, closeNotifier{base}}, rec
+	case isFlusher | isHijacker | isReaderFrom | isCloseNotifier:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+			http.CloseNotifier // nolint: staticcheck
+		}{base, flusher{base}, hijacker{base}, readerFrom{base}, closeNotifier{base}}, rec
+	case isHijacker | isReaderFrom | isCloseNotifier:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			io.ReaderFrom
+			http.CloseNotifier // nolint: staticcheck
+		}{base, hijacker{base}, readerFrom{base}, closeNotifier{base}}, rec
+	case isFlusher | isReaderFrom | isCloseNotifier:
+		return struct {
+			*responseWriter
+			http.Flusher
+			io.ReaderFrom
+			http.CloseNotifier // nolint: staticcheck
+		}{base, flusher{base}, readerFrom{base}, closeNotifier{base}}, rec
+	case isReaderFrom | isCloseNotifier:
+		return struct {
+			*responseWriter
+			io.ReaderFrom
+			http.CloseNotifier // nolint: staticcheck
+		}{base, readerFrom{base}, closeNotifier{base}}, rec
+	case isFlusher | isHijacker | isCloseNotifier:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			http.CloseNotifier // nolint: staticcheck
+		}{base, flusher{base}, hijacker{base}, closeNotifier{base}}, rec
+	case isHijacker | isCloseNotifier:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			http.CloseNotifier // nolint: staticcheck
+		}{base, hijacker{base}, closeNotifier{base}}, rec
+	case isFlusher | isCloseNotifier:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.CloseNotifier // nolint: staticcheck
+		}{base, flusher{base}, closeNotifier{base}}, rec
+	case isCloseNotifier:
+		return struct {
+			*responseWriter
+			http.CloseNotifier // nolint: staticcheck
+		}{base, closeNotifier{base}}, rec
+	case isFlusher | isHijacker | isReaderFrom | isPusher:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+		}{base, flusher{base}, hijacker{base}, readerFrom{base}, pusher{base}}, rec
+	case isHijacker | isReaderFrom | isPusher:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+		}{base, hijacker{base}, readerFrom{base}, pusher{base}}, rec
+	case isFlusher | isReaderFrom | isPusher:
+		return struct {
+			*responseWriter
+			http.Flusher
+			io.ReaderFrom
+			http.Pusher
+		}{base, flusher{base}, readerFrom{base}, pusher{base}}, rec
+	case isReaderFrom | isPusher:
+		return struct {
+			*responseWriter
+			io.ReaderFrom
+			http.Pusher
+		}{base, readerFrom{base}, pusher{base}}, rec
+	case isFlusher | isHijacker | isPusher:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{base, flusher{base}, hijacker{base}, pusher{base}}, rec
+	case isHijacker | isPusher:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			http.Pusher
+		}{base, hijacker{base}, pusher{base}}, rec
+	case isFlusher | isPusher:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Pusher
+		}{base, flusher{base}, pusher{base}}, rec
+	case isPusher:
+		return struct {
+			*responseWriter
+			http.Pusher
+		}{base, pusher{base}}, rec
+	case isFlusher | isHijacker | isReaderFrom:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{base, flusher{base}, hijacker{base}, readerFrom{base}}, rec
+	case isHijacker | isReaderFrom:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			io.ReaderFrom
+		}{base, hijacker{base}, readerFrom{base}}, rec
+	case isFlusher | isReaderFrom:
+		return struct {
+			*responseWriter
+			http.Flusher
+			io.ReaderFrom
+		}{base, flusher{base}, readerFrom{base}}, rec
+	case isReaderFrom:
+		return struct {
+			*responseWriter
+			io.ReaderFrom
+		}{base, readerFrom{base}}, rec
+	case isFlusher | isHijacker:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+		}{base, flusher{base}, hijacker{base}}, rec
+	case isHijacker:
+		return struct {
+			*responseWriter
+			http.Hijacker
+		}{base, hijacker{base}}, rec
+	case isFlusher:
+		return struct {
+			*responseWriter
+			http.Flusher
+		}{base, flusher{base}}, rec
+	default:
+		return base, rec
+	}
+}
@@ -0,0 +1,146 @@
+// Package problem provides RFC 7807 problem details error responses.
+package problem
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/emicklei/go-restful/v3"
+
+	"github.com/nova-clouds/restful-contrib/traceid"
+)
+
+// Media types of the problem details.
+const (
+	MIME_PROBLEM_JSON = "application/problem+json"
+	MIME_PROBLEM_XML  = "application/problem+xml"
+	MIME_PLAIN        = "text/plain"
+)
+
+// Details is a RFC 7807 problem details document.
+type Details struct {
+	XMLName xml.Name `json:"-" xml:"urn:ietf:rfc:7807 problem"`
+	// Type a URI reference that identifies the problem type.
+	// default: "about:blank"
+	Type string `json:"type" xml:"type"`
+	// Title a short, human-readable summary of the problem type.
+	Title string `json:"title" xml:"title"`
+	// Status the HTTP status code.
+	Status int `json:"status" xml:"status"`
+	// Detail a human-readable explanation specific to this occurrence of the problem.
+	Detail string `json:"detail,omitempty" xml:"detail,omitempty"`
+	// Instance a URI reference that identifies the specific occurrence of the problem.
+	Instance string `json:"instance,omitempty" xml:"instance,omitempty"`
+	// TraceId the trace id of the request.
+	TraceId string `json:"traceId,omitempty" xml:"traceId,omitempty"`
+}
+
+// New returns a problem details of the request with status and detail,
+// the trace id is taken from the request context.
+func New(req *restful.Request, status int, detail string) *Details {
+	return &Details{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: req.Request.URL.Path,
+		TraceId:  traceid.FromTraceId(req.Request.Context()),
+	}
+}
+
+// Write writes a problem details with status and detail to the response.
+func Write(req *restful.Request, resp *restful.Response, status int, detail string) error {
+	return WriteDetails(req, resp, New(req, status, detail))
+}
+
+// WriteDetails writes the problem details to the response,
+// the content type is negotiated by the request Accept header:
+//   - application/problem+json: application/problem+json, application/json, */* or no Accept.
+//   - application/problem+xml: application/problem+xml, application/xml, text/xml.
+//   - text/plain: text/plain.
+func WriteDetails(req *restful.Request, resp *restful.Response, d *Details) error {
+	var data []byte
+	var err error
+
+	contentType := Negotiate(req.Request.Header.Get("Accept"))
+	switch contentType {
+	case MIME_PROBLEM_XML:
+		data, err = xml.Marshal(d)
+		if err == nil {
+			data = append([]byte(xml.Header), data...)
+		}
+	case MIME_PLAIN:
+		data = []byte(d.String())
+	default:
+		data, err = json.Marshal(d)
+	}
+	if err != nil {
+		return err
+	}
+	resp.Header().Set(restful.HEADER_ContentType, contentType)
+	resp.WriteHeader(d.Status)
+	_, err = resp.Write(data)
+	return err
+}
+
+// String returns the plain text of the problem details.
+func (d *Details) String() string {
+	b := strings.Builder{}
+	b.WriteString(strconv.Itoa(d.Status))
+	b.WriteString(" ")
+	b.WriteString(d.Title)
+	if d.Detail != "" {
+		b.WriteString(": ")
+		b.WriteString(d.Detail)
+	}
+	if d.TraceId != "" {
+		b.WriteString(" (traceId: ")
+		b.WriteString(d.TraceId)
+		b.WriteString(")")
+	}
+	return b.String()
+}
+
+// Negotiate returns the content type of the problem details which the Accept header prefers.
+// It returns MIME_PROBLEM_JSON if no one is acceptable, the media type with q=0 is not acceptable.
+func Negotiate(accept string) string {
+	if accept == "" {
+		return MIME_PROBLEM_JSON
+	}
+	contentType := MIME_PROBLEM_JSON
+	best := 0.0
+	for _, v := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(v))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		var ct string
+		switch mediaType {
+		case MIME_PROBLEM_JSON, restful.MIME_JSON, "application/*", "*/*":
+			ct = MIME_PROBLEM_JSON
+		case MIME_PROBLEM_XML, restful.MIME_XML, "text/xml":
+			ct = MIME_PROBLEM_XML
+		case MIME_PLAIN, "text/*":
+			ct = MIME_PLAIN
+		default:
+			continue
+		}
+		if q > best {
+			contentType, best = ct, q
+		}
+	}
+	return contentType
+}
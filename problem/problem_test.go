@@ -0,0 +1,72 @@
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful/v3"
+
+	"github.com/nova-clouds/restful-contrib/traceid"
+)
+
+func TestNegotiate(t *testing.T) {
+	var tests = []struct {
+		accept string
+		want   string
+	}{
+		{"", MIME_PROBLEM_JSON},
+		{"*/*", MIME_PROBLEM_JSON},
+		{"application/json", MIME_PROBLEM_JSON},
+		{"application/xml", MIME_PROBLEM_XML},
+		{"text/html, text/plain", MIME_PLAIN},
+		{"application/json;q=0.5, application/problem+xml", MIME_PROBLEM_XML},
+		{"image/png", MIME_PROBLEM_JSON},
+		{"text/plain;q=0, application/xml;q=0.1", MIME_PROBLEM_XML},
+		{"application/xml;q=0", MIME_PROBLEM_JSON},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.accept); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	router := restful.NewContainer()
+	ws := new(restful.WebService)
+	ws.Filter(traceid.TraceId())
+	ws.Route(ws.GET("/orders").To(func(req *restful.Request, resp *restful.Response) {
+		_ = Write(req, resp, http.StatusForbidden, "Permission denied!")
+	}).Produces(MIME_PROBLEM_JSON, MIME_PLAIN))
+	router.Add(ws)
+
+	r, _ := http.NewRequestWithContext(context.TODO(), http.MethodGet, "/orders", http.NoBody)
+	r.Header.Set("X-Trace-Id", "abc")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status %d, supposed to be %d", w.Code, http.StatusForbidden)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != MIME_PROBLEM_JSON {
+		t.Errorf("content type %q, supposed to be %q", ct, MIME_PROBLEM_JSON)
+	}
+	var d Details
+	if err := json.Unmarshal(w.Body.Bytes(), &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Status != http.StatusForbidden || d.TraceId != "abc" || d.Instance != "/orders" || d.Title != "Forbidden" {
+		t.Errorf("unexpected problem details: %+v", d)
+	}
+
+	r.Header.Set("Accept", "text/plain")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if body := w.Body.String(); !strings.HasPrefix(body, "403 Forbidden: Permission denied!") {
+		t.Errorf("unexpected plain text body: %q", body)
+	}
+}
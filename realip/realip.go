@@ -0,0 +1,184 @@
+// Package realip resolves the real client ip of the request behind the trusted proxies.
+package realip
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/emicklei/go-restful/v3"
+)
+
+// Key to use when setting the real ip.
+type ctxRealIPKey struct{}
+
+// Option real ip option
+type Option func(*Resolver)
+
+// WithTrustedProxies optional the trusted proxies, it is a CIDR like "10.0.0.0/8" or a single ip,
+// the invalid one is ignored.
+// default: no trusted proxy, the remote address is always the client ip.
+func WithTrustedProxies(proxies ...string) Option {
+	return func(r *Resolver) {
+		for _, proxy := range proxies {
+			proxy = strings.TrimSpace(proxy)
+			if prefix, err := netip.ParsePrefix(proxy); err == nil {
+				r.trustedProxies = append(r.trustedProxies, prefix.Masked())
+			} else if addr, err := netip.ParseAddr(proxy); err == nil {
+				r.trustedProxies = append(r.trustedProxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			}
+		}
+	}
+}
+
+// WithTrustedPrivateProxies optional trust the loopback and private network proxies.
+func WithTrustedPrivateProxies() Option {
+	return WithTrustedProxies(
+		"127.0.0.0/8", "::1/128",
+		"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7",
+	)
+}
+
+// Resolver resolves the client ip from the "Forwarded", "X-Forwarded-For" and "X-Real-IP"
+// headers, honoring only the trusted proxies.
+type Resolver struct {
+	trustedProxies []netip.Prefix
+}
+
+// NewResolver new a resolver.
+func NewResolver(opts ...Option) *Resolver {
+	r := &Resolver{}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// RealIP is a middleware that injects the real client ip into the context of each request.
+func RealIP(opts ...Option) restful.FilterFunction {
+	r := NewResolver(opts...)
+	return func(req *restful.Request, resp *restful.Response, fc *restful.FilterChain) {
+		req.Request = req.Request.WithContext(WithRealIP(req.Request.Context(), r.Resolve(req.Request)))
+		fc.ProcessFilter(req, resp)
+	}
+}
+
+// Resolve returns the client ip of the request.
+// If the remote address is a trusted proxy, it walks the forwarded chain from right to left,
+// the first one which is not a trusted proxy is the client ip, the header precedence is:
+// "Forwarded"(RFC 7239), "X-Forwarded-For", "X-Real-IP".
+// The remote address is returned if the chain has an invalid hop before the client ip.
+func (r *Resolver) Resolve(req *http.Request) string {
+	remote, err := parseAddr(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	if !r.trusted(remote) {
+		return remote.String()
+	}
+	if hops := forwardedFor(req.Header.Values("Forwarded")); len(hops) > 0 {
+		if ip, ok := r.walk(remote, hops); ok {
+			return ip.String()
+		}
+		return remote.String()
+	}
+	if vs := req.Header.Values("X-Forwarded-For"); len(vs) > 0 {
+		var hops []string
+		for _, v := range vs {
+			hops = append(hops, strings.Split(v, ",")...)
+		}
+		if ip, ok := r.walk(remote, hops); ok {
+			return ip.String()
+		}
+		return remote.String()
+	}
+	if ip, err := parseAddr(req.Header.Get("X-Real-IP")); err == nil {
+		return ip.String()
+	}
+	return remote.String()
+}
+
+// walk walks the hops from right to left, returns the first one which is not a trusted proxy,
+// or the leftmost one if all of them are trusted.
+// It returns false if an invalid hop, like the obfuscated "unknown" or "_hidden", is found
+// before the client ip, as the hops on its left can't be verified.
+func (r *Resolver) walk(remote netip.Addr, hops []string) (netip.Addr, bool) {
+	last := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip, err := parseAddr(hops[i])
+		if err != nil {
+			return remote, false
+		}
+		last = ip
+		if !r.trusted(ip) {
+			break
+		}
+	}
+	return last, true
+}
+
+func (r *Resolver) trusted(ip netip.Addr) bool {
+	for _, p := range r.trustedProxies {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns the "for" parameters of the Forwarded header values.
+func forwardedFor(vs []string) []string {
+	var hops []string
+	for _, v := range vs {
+		for _, element := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				k, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(k, "for") {
+					hops = append(hops, strings.Trim(val, `"`))
+				}
+			}
+		}
+	}
+	return hops
+}
+
+// parseAddr parses the ip with optional port, like "1.2.3.4", "1.2.3.4:80", "[::1]:80", "::1".
+func parseAddr(s string) (netip.Addr, error) {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	} else {
+		s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	}
+	ip, err := netip.ParseAddr(s)
+	if err != nil {
+		return ip, err
+	}
+	return ip.Unmap().WithZone(""), nil
+}
+
+// WithRealIP Inject the real ip to context.
+func WithRealIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, ctxRealIPKey{}, ip)
+}
+
+// FromRealIP returns the real ip from the given context if one is present.
+// Returns the empty string if the real ip cannot be found.
+func FromRealIP(ctx context.Context) string {
+	ip, _ := ctx.Value(ctxRealIPKey{}).(string)
+	return ip
+}
+
+// ClientIP returns the real ip of the request from the context if one is present,
+// otherwise returns the host of the remote address.
+func ClientIP(r *http.Request) string {
+	if ip := FromRealIP(r.Context()); ip != "" {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
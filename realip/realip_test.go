@@ -0,0 +1,92 @@
+package realip
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	r := NewResolver(WithTrustedProxies("10.0.0.0/8", "192.168.1.1", "invalid"))
+
+	var tests = []struct {
+		name    string
+		remote  string
+		headers map[string][]string
+		want    string
+	}{
+		{
+			name:   "no proxy",
+			remote: "1.1.1.1:1234",
+			want:   "1.1.1.1",
+		},
+		{
+			name:    "untrusted remote ignores headers",
+			remote:  "1.1.1.1:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"2.2.2.2"}},
+			want:    "1.1.1.1",
+		},
+		{
+			name:    "x-forwarded-for skips trusted proxies",
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"3.3.3.3, 2.2.2.2", "192.168.1.1"}},
+			want:    "2.2.2.2",
+		},
+		{
+			name:    "x-forwarded-for all trusted",
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			want:    "10.0.0.3",
+		},
+		{
+			name:   "forwarded takes precedence",
+			remote: "10.0.0.1:1234",
+			headers: map[string][]string{
+				"Forwarded":       {`for="[2001:db8:cafe::17]:4711";proto=https, for=10.0.0.2`},
+				"X-Forwarded-For": {"2.2.2.2"},
+			},
+			want: "2001:db8:cafe::17",
+		},
+		{
+			name:    "forwarded obfuscated identifier",
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"Forwarded": {"for=_hidden, for=10.0.0.2"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "x-forwarded-for unknown falls back to the remote",
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"unknown, 10.0.0.2"}, "X-Real-Ip": {"4.4.4.4"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "x-forwarded-for invalid hop beyond the client",
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"garbage, 2.2.2.2, 10.0.0.2"}},
+			want:    "2.2.2.2",
+		},
+		{
+			name:    "x-real-ip",
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"X-Real-Ip": {"4.4.4.4"}},
+			want:    "4.4.4.4",
+		},
+		{
+			name:   "trusted remote without headers",
+			remote: "[::ffff:10.0.0.1]:1234",
+			want:   "10.0.0.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/", http.NoBody)
+			req.RemoteAddr = tt.remote
+			req.Header = tt.headers
+			if req.Header == nil {
+				req.Header = http.Header{}
+			}
+			if got := r.Resolve(req); got != tt.want {
+				t.Errorf("Resolve() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
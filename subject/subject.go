@@ -0,0 +1,22 @@
+// Package subject provides the authenticated subject carried in the context,
+// which is shared by the authorization and the loggers without their dependencies.
+package subject
+
+import (
+	"context"
+)
+
+// Key to use when setting the subject.
+type ctxSubjectKey struct{}
+
+// WithSubject Inject the authenticated subject to context.
+func WithSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, ctxSubjectKey{}, subject)
+}
+
+// FromSubject returns the authenticated subject from the given context if one is present.
+// Returns the empty string if a subject cannot be found.
+func FromSubject(ctx context.Context) string {
+	subject, _ := ctx.Value(ctxSubjectKey{}).(string)
+	return subject
+}
@@ -0,0 +1,16 @@
+package subject
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSubject(t *testing.T) {
+	if got := FromSubject(context.Background()); got != "" {
+		t.Errorf("FromSubject() = %q, want empty", got)
+	}
+	ctx := WithSubject(context.Background(), "alice")
+	if got := FromSubject(ctx); got != "alice" {
+		t.Errorf("FromSubject() = %q, want alice", got)
+	}
+}
@@ -14,6 +14,9 @@ import (
 // Key to use when setting the trace id.
 type ctxTraceIdKey struct{}
 
+// Key to use when setting the route.
+type ctxRouteKey struct{}
+
 // Config defines the config for TraceId middleware
 type Config struct {
 	traceIdHeader string
@@ -38,7 +41,8 @@ func WithNextTraceId(f func() string) Option {
 }
 
 // TraceId is a middleware that injects a trace id into the context of each
-// request. if it is empty, set to write head
+// request. if it is empty, set to write head.
+// The route template of the selected route is injected too, see FromRoute.
 //   - traceIdHeader is the name of the HTTP Header which contains the trace id.
 //     Exported so that it can be changed by developers. (default "X-Trace-Id")
 //   - nextTraceId generates the next trace id.(default NewSequence function use utilities/sequence)
@@ -58,7 +62,11 @@ func TraceId(opts ...Option) restful.FilterFunction {
 		// set response header
 		resp.ResponseWriter.Header().Set(cc.traceIdHeader, traceId)
 		// set request context
-		req.Request = req.Request.WithContext(WithTraceId(req.Request.Context(), traceId))
+		ctx := WithTraceId(req.Request.Context(), traceId)
+		if r := req.SelectedRoute(); r != nil {
+			ctx = WithRoute(ctx, r.Path())
+		}
+		req.Request = req.Request.WithContext(ctx)
 		fc.ProcessFilter(req, resp)
 	}
 }
@@ -75,6 +83,18 @@ func FromTraceId(ctx context.Context) string {
 	return traceId
 }
 
+// WithRoute Inject the route template to context, like "/users/{id}".
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, ctxRouteKey{}, route)
+}
+
+// FromRoute returns the route template from the given context if one is present.
+// Returns the empty string if a route cannot be found.
+func FromRoute(ctx context.Context) string {
+	route, _ := ctx.Value(ctxRouteKey{}).(string)
+	return route
+}
+
 func InjectNewFromTraceId(ctx, newCtx context.Context) context.Context {
 	return WithTraceId(newCtx, FromTraceId(ctx))
 }